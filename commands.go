@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+	"pdfmerger/merger"
+)
+
+var (
+	splitDir   string
+	extractDir string
+	// globalFlags are the values of the flags given before the command name
+	globalFlags map[string][]string
+)
+
+func commands() []*cli.Command {
+	commands := []*cli.Command{
+		{
+			Name:   "merge",
+			Usage:  "merge the PDF files of every project into a single file, the default without a command",
+			Flags:  mergeFlags(),
+			Action: run,
+		},
+		{
+			Name:   "plan",
+			Usage:  "print the merge plan as JSON without writing any files",
+			Flags:  planFlags(),
+			Action: runPlan,
+		},
+		{
+			Name:   "inspect",
+			Usage:  "print the page count and metadata of every input in the plan",
+			Flags:  planFlags(),
+			Action: runInspect,
+		},
+		{
+			Name:      "validate",
+			Usage:     "validate merged PDF files and report the result of each",
+			ArgsUsage: "[FILE...]",
+			Flags: []cli.Flag{
+				debugFlag(),
+				&cli.StringFlag{
+					Name:        "output-directory",
+					Aliases:     []string{"o"},
+					Usage:       "validate every PDF file in `OUTPUT` directory when no files are given",
+					Destination: &opts.OutputDir,
+				},
+			},
+			Action: runValidate,
+		},
+		{
+			Name:      "split",
+			Usage:     "split merged PDF files back into their sources",
+			ArgsUsage: "FILE...",
+			Flags: []cli.Flag{
+				debugFlag(),
+				&cli.StringFlag{
+					Name:        "split-directory",
+					Aliases:     []string{"d"},
+					Usage:       "write the sources to `DIR`, defaults to a directory named after each merged file",
+					Destination: &splitDir,
+				},
+				passwordFileFlag(),
+			},
+			Action: runSplit,
+		},
+		{
+			Name:      "extract-sources",
+			Usage:     "recover the original files attached to merged PDF files with --attach-sources and verify their hashes",
+			ArgsUsage: "FILE...",
+			Flags: []cli.Flag{
+				debugFlag(),
+				&cli.StringFlag{
+					Name:        "extract-directory",
+					Aliases:     []string{"d"},
+					Usage:       "write the sources to `DIR`, defaults to a directory named after each merged file",
+					Destination: &extractDir,
+				},
+				passwordFileFlag(),
+			},
+			Action: runExtractSources,
+		},
+	}
+	for _, command := range commands {
+		command.Before = inheritGlobalFlags
+	}
+	return commands
+}
+
+// saveGlobalFlags remembers the flags given before the command name, before
+// parsing the flags of the command overwrites them in opts with its defaults
+func saveGlobalFlags(c *cli.Context) error {
+	globalFlags = make(map[string][]string)
+	for _, f := range c.App.Flags {
+		name := f.Names()[0]
+		if !c.IsSet(name) {
+			continue
+		}
+		if _, ok := f.(*cli.StringSliceFlag); ok {
+			globalFlags[name] = c.StringSlice(name)
+		} else {
+			globalFlags[name] = []string{fmt.Sprint(c.Value(name))}
+		}
+	}
+	return nil
+}
+
+// inheritGlobalFlags sets the flags of the command that were only given
+// before the command name
+func inheritGlobalFlags(c *cli.Context) error {
+	for _, f := range c.Command.Flags {
+		name := f.Names()[0]
+		if c.IsSet(name) {
+			continue
+		}
+		for _, value := range globalFlags[name] {
+			if err := c.Set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commands other than merge keep stdout for their report and don't write a
+// log file
+func stderrLogger() zerolog.Logger {
+	return zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+}
+
+// passwordFileFlag reads the passwords of encrypted merged files, shared by
+// the commands that open them
+func passwordFileFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:        "password-file",
+		Usage:       "open encrypted files with the passwords in `FILE` with user=PASSWORD and owner=PASSWORD lines instead of $PDFMERGER_USER_PASSWORD and $PDFMERGER_OWNER_PASSWORD",
+		Destination: &opts.PasswordFile,
+	}
+}
+
+// mergedFilePasswords returns the passwords encrypted merged files are opened
+// with, from the same sources --encrypt reads them from
+func mergedFilePasswords() (merger.Passwords, error) {
+	return merger.ReadPasswords(os.Getenv("PDFMERGER_USER_PASSWORD"), os.Getenv("PDFMERGER_OWNER_PASSWORD"), opts.PasswordFile)
+}
+
+func planFromOptions(c *cli.Context) (*merger.Plan, error) {
+	if err := setPlanOptions(c); err != nil {
+		return nil, err
+	}
+	logger = stderrLogger()
+
+	m, err := merger.New(opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	return m.Plan(c.Context)
+}
+
+func runPlan(c *cli.Context) error {
+	plan, err := planFromOptions(c)
+	if err != nil {
+		return err
+	}
+	return plan.WriteJSON(os.Stdout)
+}
+
+func runInspect(c *cli.Context) error {
+	plan, err := planFromOptions(c)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tFILE\tPAGES\tVERSION\tTITLE\tAUTHOR\tCREATED")
+	failed := 0
+	for _, p := range plan.Projects {
+		for _, source := range p.Sources {
+			info, err := merger.Inspect(source.Path)
+			if err != nil {
+				logger.Warn().Msgf("unable to inspect %s: %s", source.Path, err.Error())
+				failed++
+				fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\n", p.Name, source.Path)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", p.Name, source.Path, info.Pages, info.Version, info.Title, info.Author, info.Created)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("unable to inspect %d files", failed)
+	}
+	return nil
+}
+
+func runValidate(c *cli.Context) error {
+	setLogLevel()
+	logger = stderrLogger()
+
+	files := c.Args().Slice()
+	if len(files) == 0 {
+		if opts.OutputDir == "" {
+			return fmt.Errorf("must give files to validate or use -o")
+		}
+		var err error
+		files, err = pdfFiles(opts.OutputDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	results := merger.ValidateFiles(files)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSTATUS\tDETAILS")
+	valid := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(tw, "%s\tinvalid\t%s\n", r.Path, r.Err.Error())
+			continue
+		}
+		valid++
+		fmt.Fprintf(tw, "%s\tvalid\t\n", r.Path)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d files: %d valid, %d invalid\n", len(results), valid, len(results)-valid)
+
+	return exitStatus(valid, len(results), "files")
+}
+
+// pdfFiles returns every PDF file below dir, leaving out signature files, the
+// quarantine and the directories split and extract-sources write next to a
+// merged file
+func pdfFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (path == filepath.Join(dir, merger.QuarantineDir) || isSourcesDir(path)) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".pdf" && !strings.Contains(d.Name(), "signature") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// isSourcesDir reports whether path is the default directory of split or
+// extract-sources for a merged file next to it
+func isSourcesDir(path string) bool {
+	for _, suffix := range []string{"-split", "-sources"} {
+		if strings.HasSuffix(path, suffix) {
+			if _, err := os.Stat(strings.TrimSuffix(path, suffix) + ".pdf"); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runSplit(c *cli.Context) error {
+	setLogLevel()
+	logger = stderrLogger()
+
+	files := c.Args().Slice()
+	if len(files) == 0 {
+		return fmt.Errorf("must give the merged files to split")
+	}
+	pw, err := mergedFilePasswords()
+	if err != nil {
+		return err
+	}
+
+	succeeded := 0
+	for _, file := range files {
+		dir := splitDir
+		if dir == "" {
+			dir = strings.TrimSuffix(file, filepath.Ext(file)) + "-split"
+		} else if len(files) > 1 {
+			dir = filepath.Join(dir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		}
+
+		written, err := merger.Split(file, dir, pw)
+		for _, w := range written {
+			logger.Info().Msgf("wrote %s", w)
+		}
+		if err != nil {
+			logger.Warn().Msgf("error splitting %s: %s", file, err.Error())
+			continue
+		}
+		succeeded++
+	}
+
+	return exitStatus(succeeded, len(files), "files")
+}
+
+func runExtractSources(c *cli.Context) error {
+	setLogLevel()
+	logger = stderrLogger()
+
+	files := c.Args().Slice()
+	if len(files) == 0 {
+		return fmt.Errorf("must give the merged files to extract the sources of")
+	}
+	pw, err := mergedFilePasswords()
+	if err != nil {
+		return err
+	}
+
+	succeeded := 0
+	for _, file := range files {
+		dir := extractDir
+		if dir == "" {
+			dir = strings.TrimSuffix(file, filepath.Ext(file)) + "-sources"
+		} else if len(files) > 1 {
+			dir = filepath.Join(dir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		}
+
+		written, err := merger.ExtractSources(file, dir, pw)
+		for _, w := range written {
+			logger.Info().Msgf("wrote %s", w)
+		}
+		if err != nil {
+			logger.Warn().Msgf("error extracting sources of %s: %s", file, err.Error())
+			continue
+		}
+		succeeded++
+	}
+
+	return exitStatus(succeeded, len(files), "files")
+}
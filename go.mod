@@ -6,7 +6,7 @@ require (
 	github.com/pdfcpu/pdfcpu v0.4.1
 	github.com/rs/zerolog v1.29.1
 	github.com/urfave/cli/v2 v2.25.7
-	golang.org/x/exp v0.0.0-20230522175609-2e198f4a06a1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -23,5 +23,4 @@ require (
 	golang.org/x/image v0.8.0 // indirect
 	golang.org/x/sys v0.9.0 // indirect
 	golang.org/x/text v0.10.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -1,80 +1,354 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"github.com/pdfcpu/pdfcpu/pkg/api"
-	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/rs/zerolog"
 	"github.com/urfave/cli/v2"
-	"golang.org/x/exp/slices"
-	"io/fs"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"sort"
+	"pdfmerger/merger"
 	"strings"
+	"syscall"
+)
+
+// exit codes besides 0 for success and 1 for errors before merging
+const (
+	exitPartialFailure = 2
+	exitTotalFailure   = 3
+	// the code shells use for programs ended by Ctrl-C
+	exitInterrupted = 130
 )
 
 var (
-	inputDir       string
-	outputDir      string
-	projects       map[string][]string
-	signatureFiles map[string][]string
-	debug          bool           = false
-	logger         zerolog.Logger = zerolog.New(zerolog.MultiLevelWriter(zerolog.NewConsoleWriter())).With().Timestamp().Logger()
+	opts   merger.Options
+	dryRun bool
+	debug  bool           = false
+	logger zerolog.Logger = zerolog.New(zerolog.MultiLevelWriter(zerolog.NewConsoleWriter())).With().Timestamp().Logger()
 )
 
 func main() {
-	app := &cli.App{
-		Name:   "pdfmerger",
-		Usage:  "takes a directory of PDF files and merges them by project",
-		Flags:  flags(),
-		Action: run,
-	}
-
-	err := app.Run(os.Args)
+	err := newApp().Run(os.Args)
 	if err != nil {
 		logger.Fatal().Msgf("error running program: %s", err.Error())
 	}
 }
 
-func flags() []cli.Flag {
+func newApp() *cli.App {
+	return &cli.App{
+		Name:  "pdfmerger",
+		Usage: "takes a directory of PDF files and merges them by project",
+		Flags: mergeFlags(),
+		// merging stays the default so invocations without a command keep working
+		Action:   run,
+		Commands: commands(),
+		Before:   saveGlobalFlags,
+		// signature rules contain regexps, which may contain commas
+		DisableSliceFlagSeparator: true,
+	}
+}
+
+func debugFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:        "debug",
+		Usage:       "set debug logging",
+		Destination: &debug,
+	}
+}
+
+// planFlags select and group the inputs, shared by every command that builds
+// a plan
+func planFlags() []cli.Flag {
 	return []cli.Flag{
-		&cli.BoolFlag{
-			Name:        "debug",
-			Usage:       "set debug logging",
-			Destination: &debug,
-		},
+		debugFlag(),
 		&cli.StringFlag{
 			Name:        "input-directory",
 			Aliases:     []string{"i"},
 			Usage:       "read PDF files from `INPUT` directory",
 			Required:    false,
-			Destination: &inputDir,
+			Destination: &opts.InputDir,
 		},
 		&cli.StringFlag{
 			Name:        "output-directory",
 			Aliases:     []string{"o"},
 			Usage:       "write merged PDF files to `OUTPUT` directory",
 			Required:    false,
-			Destination: &outputDir,
+			Destination: &opts.OutputDir,
+		},
+		&cli.StringFlag{
+			Name:        "group-pattern",
+			Aliases:     []string{"g"},
+			Usage:       "group PDF files with `REGEXP` matched against the file name without extension, using the named groups \"project\" and optionally \"seq\"",
+			EnvVars:     []string{"PDFMERGER_GROUP_PATTERN"},
+			Value:       merger.DefaultGroupPattern,
+			Destination: &opts.GroupPattern,
+		},
+		&cli.StringFlag{
+			Name:        "recursive",
+			Aliases:     []string{"r"},
+			Usage:       "descend into subdirectories, `MODE` is \"flatten\" to group the whole tree by file name or \"directory\" to make each subdirectory a project named after its relative path",
+			Destination: &opts.Recursive,
+		},
+		&cli.StringFlag{
+			Name:        "symlinks",
+			Usage:       "how to treat symlinks in the input directory, `POLICY` is one of \"follow\", \"skip\" or \"error\"",
+			Value:       "follow",
+			Destination: &opts.Symlinks,
+		},
+		&cli.StringFlag{
+			Name:        "order",
+			Usage:       "order files within a project by `STRATEGY`: \"natural\" file name, \"seq\" group, \"mtime\" or PDF \"created\" date",
+			Value:       "natural",
+			Destination: &opts.Order,
+		},
+		&cli.StringFlag{
+			Name:        "manifest",
+			Aliases:     []string{"m"},
+			Usage:       "merge the projects listed in the YAML, JSON or CSV `FILE` instead of scanning the input directory",
+			Destination: &opts.Manifest,
+		},
+		&cli.StringFlag{
+			Name:        "signature-dir",
+			Aliases:     []string{"s"},
+			Usage:       "read signature-SUFFIX.pdf files from `SIGNATURES` directory instead of the output directory",
+			Destination: &opts.SignatureDir,
+		},
+		&cli.StringSliceFlag{
+			Name:  "signature-rule",
+			Usage: "place signature sets by `SET=PLACEMENT[@PROJECT]`, PLACEMENT is \"after\" or \"before\" matching documents, \"end\" or \"none\", can be repeated and the first matching rule wins",
 		},
 	}
 }
 
-func parseSignatureFiles() error {
-	signatureFiles = make(map[string][]string)
-	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
-		if strings.Contains(d.Name(), "signature") {
-			basename := strings.Replace(strings.Replace(d.Name(), filepath.Ext(d.Name()), "", -1), "signature-", "", -1)
-			logger.Debug().Msgf("signature file basename: %v\n", basename)
-			suffix := strings.Split(basename, ".")[0]
-			signatureFiles[suffix] = append(signatureFiles[suffix], path)
-			logger.Debug().Msgf("adding file %v to suffix %v\n", path, suffix)
-		}
-		return err
-	})
-	return err
+// mergeFlags are the plan flags plus the ones that only matter when merging
+func mergeFlags() []cli.Flag {
+	return append(planFlags(),
+		&cli.IntFlag{
+			Name:        "jobs",
+			Aliases:     []string{"j"},
+			Usage:       "merge up to `N` projects in parallel",
+			Value:       1,
+			Destination: &opts.Jobs,
+		},
+		&cli.BoolFlag{
+			Name:        "bookmarks",
+			Aliases:     []string{"b"},
+			Usage:       "add a bookmark for every source document and signature set",
+			Destination: &opts.Bookmarks,
+		},
+		&cli.StringFlag{
+			Name:        "bookmark-title",
+			Usage:       "name bookmarks with the Go `TEMPLATE`, using .Name, .File, .Path, .Project, .Groups.NAME, .Signature and .Set",
+			Value:       merger.DefaultBookmarkTitle,
+			Destination: &opts.BookmarkTitle,
+		},
+		&cli.StringFlag{
+			Name:        "bookmark-group",
+			Usage:       "nest bookmarks under parents named after the captured `GROUP` of the group pattern, such as a document type",
+			Destination: &opts.BookmarkGroup,
+		},
+		&cli.BoolFlag{
+			Name:        "toc",
+			Usage:       "start every merged file with a table of contents linking to its documents, named like bookmarks",
+			Destination: &opts.TableOfContents,
+		},
+		&cli.StringFlag{
+			Name:        "bates",
+			Usage:       "stamp every page with a Bates number starting with `PREFIX`, counting on across projects and runs",
+			Destination: &opts.BatesPrefix,
+		},
+		&cli.IntFlag{
+			Name:        "bates-digits",
+			Usage:       "pad Bates numbers to `N` digits",
+			Value:       6,
+			Destination: &opts.BatesDigits,
+		},
+		&cli.IntFlag{
+			Name:        "bates-start",
+			Usage:       "start a Bates prefix no earlier run used at `N`",
+			Value:       1,
+			Destination: &opts.BatesStart,
+		},
+		&cli.StringFlag{
+			Name:        "bates-font",
+			Usage:       "stamp Bates numbers in the core PDF `FONT`",
+			Value:       "Helvetica",
+			Destination: &opts.BatesFont,
+		},
+		&cli.IntFlag{
+			Name:        "bates-font-size",
+			Usage:       "stamp Bates numbers at `POINTS` size",
+			Value:       9,
+			Destination: &opts.BatesFontSize,
+		},
+		&cli.StringFlag{
+			Name:        "bates-position",
+			Usage:       "stamp Bates numbers at `ANCHOR`, one of tl, tc, tr, l, c, r, bl, bc or br",
+			Value:       "br",
+			Destination: &opts.BatesPosition,
+		},
+		&cli.StringFlag{
+			Name:        "title",
+			Usage:       "set the document title to the Go `TEMPLATE`, using .Project, .Date and .SourceCount",
+			Destination: &opts.Title,
+		},
+		&cli.StringFlag{
+			Name:        "author",
+			Usage:       "set the document author to the Go `TEMPLATE`",
+			Destination: &opts.Author,
+		},
+		&cli.StringFlag{
+			Name:        "subject",
+			Usage:       "set the document subject to the Go `TEMPLATE`",
+			Destination: &opts.Subject,
+		},
+		&cli.StringFlag{
+			Name:        "creator",
+			Usage:       "set the document creator to the Go `TEMPLATE`",
+			Destination: &opts.Creator,
+		},
+		&cli.StringFlag{
+			Name:        "keywords",
+			Usage:       "set the document keywords to the Go `TEMPLATE`, separated by commas",
+			Destination: &opts.Keywords,
+		},
+		&cli.BoolFlag{
+			Name:        "source-properties",
+			Usage:       "list the merged file names in the custom document properties Source1 to SourceN",
+			Destination: &opts.SourceProperties,
+		},
+		&cli.BoolFlag{
+			Name:        "attach-sources",
+			Usage:       "attach the original files and a manifest of their hashes to every merged file, see extract-sources",
+			Destination: &opts.AttachSources,
+		},
+		&cli.StringFlag{
+			Name:        "page-size",
+			Usage:       "bring every page to the paper `SIZE` such as A4 or Letter, or WIDTHxHEIGHT in points, optionally followed by :POLICY",
+			Destination: &opts.PageSize,
+		},
+		&cli.StringFlag{
+			Name:        "page-size-policy",
+			Usage:       "bring pages to the page size by `POLICY`: \"fit\" scales them to fit, \"fill\" scales them to cover it and crops the rest, \"center\" only pads or crops",
+			Value:       "fit",
+			Destination: &opts.PageSizePolicy,
+		},
+		&cli.StringSliceFlag{
+			Name:  "page-size-rule",
+			Usage: "override the page size for files matching a glob by `GLOB=SIZE[:POLICY]` or GLOB=none, can be repeated and the first matching rule wins",
+		},
+		&cli.StringFlag{
+			Name:        "orientation",
+			Usage:       "rotate every page that isn't in `ORIENTATION`, \"portrait\" or \"landscape\"",
+			Destination: &opts.Orientation,
+		},
+		&cli.StringSliceFlag{
+			Name:  "orientation-rule",
+			Usage: "override the orientation for files matching a glob by `GLOB=ORIENTATION`, ORIENTATION can also be \"none\", can be repeated and the first matching rule wins",
+		},
+		&cli.IntFlag{
+			Name:        "rotate-degrees",
+			Usage:       "rotate pages by `DEGREES`, 90 clockwise or -90 counterclockwise",
+			Value:       90,
+			Destination: &opts.RotateDegrees,
+		},
+		&cli.BoolFlag{
+			Name:        "duplex",
+			Usage:       "add a blank page after every document with an odd number of pages, so each starts on a right-hand page when printed double-sided",
+			Destination: &opts.Duplex,
+		},
+		&cli.StringFlag{
+			Name:        "blank-page-text",
+			Usage:       "stamp `TEXT` such as \"This page intentionally left blank\" on the blank pages added by --duplex",
+			Destination: &opts.BlankPageText,
+		},
+		&cli.BoolFlag{
+			Name:        "encrypt",
+			Usage:       "encrypt every output with the passwords from $PDFMERGER_USER_PASSWORD and $PDFMERGER_OWNER_PASSWORD, --password-file or --password-csv",
+			Destination: &opts.Encrypt,
+		},
+		&cli.StringFlag{
+			Name:        "password-file",
+			Usage:       "read the passwords for --encrypt from `FILE` with user=PASSWORD and owner=PASSWORD lines",
+			Destination: &opts.PasswordFile,
+		},
+		&cli.StringFlag{
+			Name:        "password-csv",
+			Usage:       "read passwords for --encrypt per project from `FILE` with the columns project, user_password and owner_password",
+			Destination: &opts.PasswordCSV,
+		},
+		&cli.IntFlag{
+			Name:        "key-length",
+			Usage:       "encrypt with AES keys of `BITS`, 128 or 256",
+			Value:       256,
+			Destination: &opts.EncryptKeyLength,
+		},
+		&cli.StringFlag{
+			Name:        "permissions",
+			Usage:       "restrict encrypted outputs to `PRESET`: \"none\", \"print\" or \"all\"",
+			Value:       "print",
+			Destination: &opts.Permissions,
+		},
+		&cli.StringFlag{
+			Name:        "input-password-file",
+			Usage:       "decrypt encrypted inputs with the password of the first glob matching their file name in `FILE` with GLOB=PASSWORD lines, before trying $PDFMERGER_INPUT_PASSWORD",
+			Destination: &opts.InputPasswordFile,
+		},
+		&cli.StringFlag{
+			Name:        "on-encrypted",
+			Usage:       "`POLICY` for encrypted inputs none of the passwords decrypts, \"fail\" to fail the project or \"skip\" to leave them out",
+			Value:       "fail",
+			Destination: &opts.OnEncrypted,
+		},
+		&cli.StringFlag{
+			Name:        "on-invalid",
+			Usage:       "`POLICY` for inputs that fail validation, \"fail\" to fail the project or \"skip\" to leave them out, either way they go to the quarantine directory in the output directory",
+			Value:       "fail",
+			Destination: &opts.OnInvalid,
+		},
+		&cli.StringFlag{
+			Name:        "quarantine-mode",
+			Usage:       "\"copy\" or \"move\" invalid inputs to the quarantine directory by `MODE`",
+			Value:       "copy",
+			Destination: &opts.QuarantineMode,
+		},
+		&cli.StringFlag{
+			Name:        "input-validation",
+			Usage:       "validate inputs before merging in `MODE`, \"strict\", \"relaxed\" or \"none\"",
+			Value:       "relaxed",
+			Destination: &opts.InputValidation,
+		},
+		&cli.StringFlag{
+			Name:        "output-validation",
+			Usage:       "validate merged files in `MODE`, \"strict\", \"relaxed\" or \"none\"",
+			Value:       "relaxed",
+			Destination: &opts.OutputValidation,
+		},
+		&cli.BoolFlag{
+			Name:        "validate-links",
+			Usage:       "also check that the URLs of links in inputs and merged files can be reached",
+			Destination: &opts.ValidateLinks,
+		},
+		&cli.BoolFlag{
+			Name:        "force",
+			Usage:       "rebuild every project, also those whose sources and options didn't change since the last build",
+			Destination: &opts.Force,
+		},
+		&cli.BoolFlag{
+			Name:        "fail-fast",
+			Usage:       "stop merging after the first project that fails",
+			Destination: &opts.FailFast,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Aliases:     []string{"n"},
+			Usage:       "print the merge plan as JSON instead of writing any files",
+			Destination: &dryRun,
+		},
+	)
 }
 
 // func checkAndSetSignatureFiles(argsLine string) (string, error) {
@@ -101,11 +375,18 @@ func parseSignatureFiles() error {
 // }
 
 func checkAndSetAlternateDirectories(args []string) error {
-	if inputDir != "" && outputDir != "" {
+	if opts.Manifest != "" {
+		if opts.OutputDir == "" {
+			return errors.New("must use -o with --manifest")
+		}
 		return nil
 	}
 
-	if (inputDir != "" && outputDir == "") || (inputDir == "" && outputDir != "") {
+	if opts.InputDir != "" && opts.OutputDir != "" {
+		return nil
+	}
+
+	if (opts.InputDir != "" && opts.OutputDir == "") || (opts.InputDir == "" && opts.OutputDir != "") {
 		return errors.New("must use both -i and -o or neither")
 	}
 
@@ -126,39 +407,82 @@ func checkAndSetAlternateDirectories(args []string) error {
 		return fmt.Errorf("split line does not end up with two directories: %v", splitLine)
 	}
 
-	inputDir = strings.TrimSpace(splitLine[0])
-	outputDir = strings.TrimSpace(splitLine[1])
+	opts.InputDir = strings.TrimSpace(splitLine[0])
+	opts.OutputDir = strings.TrimSpace(splitLine[1])
 
 	return nil
 }
 
-func run(c *cli.Context) error {
+func setLogLevel() {
 	if debug {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	} else {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
+}
 
-	if err := checkAndSetAlternateDirectories(c.Args().Slice()); err != nil {
-		return err
-	}
+// setPlanOptions fills in the options shared by every command that builds a
+// plan from the flags and positional arguments
+func setPlanOptions(c *cli.Context) error {
+	setLogLevel()
 
-	if err := parseSignatureFiles(); err != nil {
+	if err := checkAndSetAlternateDirectories(c.Args().Slice()); err != nil {
 		return err
 	}
-
-	// return nil
+	opts.SignatureRules = c.StringSlice("signature-rule")
+	opts.PageSizeRules = c.StringSlice("page-size-rule")
+	opts.OrientationRules = c.StringSlice("orientation-rule")
+	// passwords are only read from the environment, never from flags that
+	// end up in the shell history and process list
+	opts.UserPassword = os.Getenv("PDFMERGER_USER_PASSWORD")
+	opts.OwnerPassword = os.Getenv("PDFMERGER_OWNER_PASSWORD")
+	opts.InputPassword = os.Getenv("PDFMERGER_INPUT_PASSWORD")
 
 	logger.Debug().Msgf(`
     input dir: %v
     output dir: %v
-    signature file: %v
-	`, inputDir, outputDir, signatureFiles)
+    group pattern: %v
+    recursive: %v
+    symlinks: %v
+    order: %v
+    manifest: %v
+    jobs: %v
+    fail fast: %v
+    signature dir: %v
+    signature rules: %v
+	`, opts.InputDir, opts.OutputDir, opts.GroupPattern, opts.Recursive, opts.Symlinks, opts.Order, opts.Manifest, opts.Jobs, opts.FailFast, opts.SignatureDir, opts.SignatureRules)
+
+	return nil
+}
+
+func run(c *cli.Context) error {
+	if dryRun {
+		return runPlan(c)
+	}
+
+	// only a merge catches interrupts: the first one stops starting projects
+	// and lets the running ones clean up their temporary files, the second
+	// one ends the program
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := setPlanOptions(c); err != nil {
+		return err
+	}
+
+	m, err := merger.New(opts, logger)
+	if err != nil {
+		return err
+	}
 
 	// create output directory if it doesn't exist
-	if _, err := os.Stat(outputDir); err != nil {
+	if _, err := os.Stat(opts.OutputDir); err != nil {
 		if os.IsNotExist(err) {
-			err := os.MkdirAll(outputDir, 0755)
+			err := os.MkdirAll(opts.OutputDir, 0755)
 			if err != nil {
 				return fmt.Errorf("unable to create output directory: %s", err.Error())
 			}
@@ -167,150 +491,55 @@ func run(c *cli.Context) error {
 		}
 	}
 
-	f, err := os.Create(filepath.Join(outputDir, "log.txt"))
+	f, err := os.Create(filepath.Join(opts.OutputDir, "log.txt"))
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	// main logs the returned error after log.txt is closed
+	consoleLogger := logger
+	defer func() { logger = consoleLogger }()
 	logger = zerolog.New(zerolog.MultiLevelWriter(
 		zerolog.NewConsoleWriter(),
 		zerolog.ConsoleWriter{Out: f, NoColor: true})).With().Timestamp().Logger()
+	m = m.WithLogger(logger)
 
-	projects = make(map[string][]string)
-	err = filepath.Walk(inputDir, walkFunc)
+	plan, err := m.Plan(ctx)
 	if err != nil {
 		logger.Fatal().Msgf("error scanning files: %s", err.Error())
 	}
 
-	sortedProjectNames := sortProjects(projects)
-
-	for _, pName := range sortedProjectNames {
-		err = mergePDF(pName, projects[pName])
-		if err != nil {
-			logger.Warn().Msgf("error merging PDFs: %s", err.Error())
+	results, err := m.Execute(ctx, plan)
+	if errors.Is(err, context.Canceled) {
+		// still show which projects were finished before the interrupt
+		if err := merger.WriteSummary(io.MultiWriter(os.Stdout, f), results); err != nil {
+			return err
 		}
+		logger.Warn().Msg("interrupted")
+		return cli.Exit("interrupted", exitInterrupted)
 	}
-
-	return nil
-}
-
-func sortProjects(projects map[string][]string) []string {
-	projectNames := []string{}
-	for p := range projects {
-		projectNames = append(projectNames, p)
-	}
-	sort.Strings(projectNames)
-	return projectNames
-}
-
-func walkFunc(path string, info os.FileInfo, err error) error {
 	if err != nil {
 		return err
 	}
 
-	if info.IsDir() && info.Name() != ".." && info.Name() != filepath.Base(inputDir) {
-		logger.Info().Msgf("skipping directory: %s", info.Name())
-		return filepath.SkipDir
-	}
-
-	if info.Name() == ".." {
-		return nil
-	}
-
-	_, file := filepath.Split(path)
-
-	if filepath.Ext(file) != ".pdf" {
-		logger.Info().Msgf("skipping non-pdf file: %s\n", path)
-		return nil
-	}
-
-	// if we're here, have some file.pdf to work with
-	projectName := parseProjectName(file)
-
-	projects[projectName] = append(projects[projectName], path)
-
-	return nil
-}
-
-// split pdf name by underscare and take index 1 as the project name
-func parseProjectName(file string) string {
-
-	cleanPath := strings.TrimSuffix(file, filepath.Ext(file))
-
-	slice := strings.Split(cleanPath, "-")
-
-	if len(slice) < 2 {
-		return cleanPath
+	if err := merger.WriteSummary(io.MultiWriter(os.Stdout, f), results); err != nil {
+		return err
 	}
 
-	//joined := strings.Join(slice[0:2], "_")
-	return slice[0]
+	// unchanged projects are as good as rebuilt ones
+	succeeded := merger.Count(results, merger.StatusSuccess) + merger.Count(results, merger.StatusUnchanged)
+	return exitStatus(succeeded, len(results), "projects")
 }
 
-func mergePDF(project string, projectFiles []string) error {
-
-	sort.Slice(projectFiles, func(i, j int) bool {
-		replacedI := strings.ReplaceAll(projectFiles[i], "-", "")
-		replacedJ := strings.ReplaceAll(projectFiles[j], "-", "")
-
-		return replacedI < replacedJ
-	})
-
-	// if signatureFile != "" {
-	// 	projectFiles = append(projectFiles, signatureFile)
-	// }
-
-	logger.Info().Msgf("order of merging into project %s:", project)
-	sigAddedProjectFiles := addSigFiles(projectFiles)
-	for _, file := range sigAddedProjectFiles {
-		logger.Info().Msgf(file)
-	}
-
-	outputFile := filepath.Join(outputDir, project+".pdf")
-
-	mergeConf := model.NewDefaultConfiguration()
-	mergeConf.ValidationMode = model.ValidationNone
-	err := api.MergeCreateFile(sigAddedProjectFiles, outputFile, mergeConf)
-	if err != nil {
-		return err
-	}
-	if err := api.ValidateFile(outputFile, mergeConf); err != nil {
-		return err
+// exitStatus turns the number of successful items into the exit code of the
+// program, 0 if every item succeeded, exitPartialFailure if only some did and
+// exitTotalFailure if none did
+func exitStatus(succeeded, total int, items string) error {
+	if succeeded == total {
+		return nil
 	}
-	logger.Info().Msgf("successfully validated file: %s", outputFile)
-	return nil
-}
-
-func addSigFiles(projectFiles []string) []string {
-	tempFiles := []string{}
-	tempFiles = append(tempFiles, projectFiles...)
-	for i, name := range tempFiles {
-		if strings.Contains(name, "signature") {
-			logger.Debug().Msgf("skipping signature file: %v\n", name)
-			continue
-		}
-		split := strings.Split(name, "-")
-		logger.Debug().Msgf("split of file: %v, %#v\n", name, split)
-		if len(split) < 2 {
-			continue
-		}
-		suffix := strings.Replace(split[1], filepath.Ext(split[1]), "", -1)
-
-		logger.Debug().Msgf("found suffix of file: %v, suffix %v\n", name, suffix)
-		logger.Debug().Msgf("sig files map %+v\n", signatureFiles)
-
-		if foundSuffix, ok := signatureFiles[suffix]; ok {
-			logger.Debug().Msgf("inserting signature files %+v\n", signatureFiles[suffix])
-			idx := slices.Index(tempFiles, name)
-			if i+1 == len(tempFiles) {
-				logger.Debug().Msgf("appending to %+v\n", tempFiles)
-				tempFiles = append(tempFiles, foundSuffix...)
-			} else {
-				logger.Debug().Msgf("inserting into %+v\n", tempFiles)
-				tempFiles = slices.Insert(tempFiles, idx+1, foundSuffix...)
-			}
-		}
+	if succeeded == 0 {
+		return cli.Exit(fmt.Sprintf("none of the %d %s succeeded", total, items), exitTotalFailure)
 	}
-	logger.Debug().Msgf("temp project files after adding sig file: %#v\n", tempFiles)
-	return tempFiles
+	return cli.Exit(fmt.Sprintf("only %d of %d %s succeeded", succeeded, total, items), exitPartialFailure)
 }
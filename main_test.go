@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"pdfmerger/merger"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		succeeded, total int
+		code             int
+		message          string
+	}{
+		{succeeded: 3, total: 3, code: 0},
+		{succeeded: 0, total: 0, code: 0},
+		{succeeded: 2, total: 3, code: exitPartialFailure, message: "only 2 of 3 projects succeeded"},
+		{succeeded: 0, total: 3, code: exitTotalFailure, message: "none of the 3 projects succeeded"},
+	}
+	for _, test := range tests {
+		err := exitStatus(test.succeeded, test.total, "projects")
+		if test.code == 0 {
+			if err != nil {
+				t.Errorf("exitStatus(%d, %d) = %v, want nil", test.succeeded, test.total, err)
+			}
+			continue
+		}
+		exit, ok := err.(cli.ExitCoder)
+		if !ok {
+			t.Errorf("exitStatus(%d, %d) = %v, want an exit code", test.succeeded, test.total, err)
+			continue
+		}
+		if exit.ExitCode() != test.code || exit.Error() != test.message {
+			t.Errorf("exitStatus(%d, %d) = %d %q, want %d %q",
+				test.succeeded, test.total, exit.ExitCode(), exit.Error(), test.code, test.message)
+		}
+	}
+}
+
+func TestGlobalFlagsBeforeCommand(t *testing.T) {
+	in, out := t.TempDir(), t.TempDir()
+	tests := []struct {
+		name  string
+		args  []string
+		check func(merger.Options) bool
+	}{
+		{
+			name:  "directories before plan",
+			args:  []string{"-i", in, "-o", out, "plan"},
+			check: func(o merger.Options) bool { return o.InputDir == in && o.OutputDir == out },
+		},
+		{
+			name:  "order before plan",
+			args:  []string{"--order", "mtime", "plan", "-i", in, "-o", out},
+			check: func(o merger.Options) bool { return o.Order == "mtime" },
+		},
+		{
+			name:  "the command flag wins",
+			args:  []string{"--order", "mtime", "plan", "--order", "seq", "-i", in, "-o", out},
+			check: func(o merger.Options) bool { return o.Order == "seq" },
+		},
+		{
+			name:  "signature rules before plan",
+			args:  []string{"--signature-rule", "*=none", "--signature-rule", "1=end", "plan", "-i", in, "-o", out},
+			check: func(o merger.Options) bool { return reflect.DeepEqual(o.SignatureRules, []string{"*=none", "1=end"}) },
+		},
+		{
+			name:  "jobs before merge",
+			args:  []string{"--jobs", "4", "merge", "-i", in, "-o", out},
+			check: func(o merger.Options) bool { return o.Jobs == 4 },
+		},
+		{
+			name:  "output directory before validate",
+			args:  []string{"-o", out, "validate"},
+			check: func(o merger.Options) bool { return o.OutputDir == out },
+		},
+	}
+
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	defaultLogger := logger
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts = merger.Options{}
+			logger = defaultLogger
+			os.Stdout = devNull
+			err := newApp().Run(append([]string{"pdfmerger"}, test.args...))
+			os.Stdout = stdout
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(opts) {
+				t.Errorf("got options %+v", opts)
+			}
+		})
+	}
+	logger = defaultLogger
+}
@@ -0,0 +1,169 @@
+package merger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// SourcesAttachment is the name of the manifest attached next to the sources.
+const SourcesAttachment = "pdfmerger-sources.json"
+
+// AttachedSource describes an original source attached to a merged output.
+type AttachedSource struct {
+	// Name is the name of the attachment.
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Signature bool   `json:"signature,omitempty"`
+}
+
+// SourcesManifest is the SourcesAttachment of a merged output.
+type SourcesManifest struct {
+	Project string           `json:"project"`
+	Sources []AttachedSource `json:"sources"`
+}
+
+// addSources attaches the original file of every source plus a manifest
+// with their hashes to merged. It adds the attachments to the context
+// itself, api.AddAttachments takes file names and treats commas in them as
+// the start of a description.
+func (m *Merger) addSources(p ProjectPlan, merged []byte) ([]byte, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	conf.Cmd = model.ADDATTACHMENTS
+	ctx, err := api.ReadContext(bytes.NewReader(merged), conf)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := SourcesManifest{Project: p.Name}
+	used := map[string]int{SourcesAttachment: 1}
+	for _, source := range p.Sources {
+		data, err := os.ReadFile(source.Path)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(source.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		// signature files and sources from different directories can share a
+		// name, attachment names have to be unique
+		name := filepath.Base(source.Path)
+		used[name]++
+		if used[name] > 1 {
+			ext := filepath.Ext(name)
+			name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), used[name], ext)
+		}
+
+		sum := sha256.Sum256(data)
+		manifest.Sources = append(manifest.Sources, AttachedSource{
+			Name:      name,
+			Path:      source.Path,
+			Size:      int64(len(data)),
+			SHA256:    hex.EncodeToString(sum[:]),
+			Signature: source.Signature,
+		})
+
+		modTime := fi.ModTime()
+		if err := ctx.AddAttachment(model.Attachment{Reader: bytes.NewReader(data), ID: name, ModTime: &modTime}, false); err != nil {
+			return nil, fmt.Errorf("unable to attach %s: %s", source.Path, err.Error())
+		}
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.AddAttachment(model.Attachment{Reader: bytes.NewReader(b), ID: SourcesAttachment, Desc: "pdfmerger sources"}, false); err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	if err := api.WriteContext(ctx, out); err != nil {
+		return nil, err
+	}
+	m.logger.Info().Msgf("attached %d sources to %s", len(manifest.Sources), p.Output)
+	return out.Bytes(), nil
+}
+
+// ExtractSources writes the sources attached to a merged output into dir,
+// checking each against the hash recorded when it was attached, and returns
+// the written files. An encrypted output is opened with pw.
+func ExtractSources(output, dir string, pw Passwords) ([]string, error) {
+	f, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// without validation pdfcpu doesn't find any attachments
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.EXTRACTATTACHMENTS
+	pw.open(conf)
+	attachments, err := api.ExtractAttachmentsRaw(f, dir, nil, conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read attachments of %s: %s", output, err.Error())
+	}
+
+	contents := make(map[string][]byte)
+	for _, a := range attachments {
+		data, err := io.ReadAll(a)
+		if err != nil {
+			return nil, err
+		}
+		contents[a.FileName] = data
+	}
+
+	b, ok := contents[SourcesAttachment]
+	if !ok {
+		return nil, fmt.Errorf("no attached sources in %s, was it merged with --attach-sources?", output)
+	}
+	manifest := SourcesManifest{}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %s", SourcesAttachment, output, err.Error())
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	written := []string{}
+	failed := []string{}
+	for _, source := range manifest.Sources {
+		data, ok := contents[source.Name]
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s is missing", source.Name))
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != source.SHA256 || int64(len(data)) != source.Size {
+			failed = append(failed, fmt.Sprintf("%s doesn't match its hash", source.Name))
+			continue
+		}
+
+		file := filepath.Join(dir, filepath.Base(source.Name))
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return written, err
+		}
+		written = append(written, file)
+	}
+
+	if len(failed) > 0 {
+		return written, errors.New(strings.Join(failed, ", "))
+	}
+	return written, nil
+}
@@ -0,0 +1,238 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	// batesStateFile keeps the next Bates number per prefix between runs
+	batesStateFile = ".pdfmerger-bates.json"
+	// batesMappingFile gets a line per source and Bates range appended
+	batesMappingFile = "bates.csv"
+	// distance of the Bates number from the page edges, in points
+	batesMargin = 20
+)
+
+var batesPositions = []string{"tl", "tc", "tr", "l", "c", "r", "bl", "bc", "br"}
+
+// batesCounter hands out Bates numbers to the projects of a single Execute.
+// Projects take their turn in plan order and keep it until their output is
+// in place, so the numbers don't depend on how many run in parallel and a
+// failing project doesn't use any up.
+type batesCounter struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string
+	digits   int
+	state    map[string]int
+	turns    []chan struct{}
+	released []bool
+}
+
+// batesNumbers are the numbers a project stamped and the mapping rows for
+// them, saved by Execute once its output is in place
+type batesNumbers struct {
+	next int
+	rows [][]string
+}
+
+// loadBates reads the counters left by earlier runs in the output directory,
+// starting at BatesStart for a prefix that has none
+func (m *Merger) loadBates(projects int) (*batesCounter, error) {
+	dir, prefix := m.opts.OutputDir, m.opts.BatesPrefix
+	b := &batesCounter{
+		dir:      dir,
+		prefix:   prefix,
+		digits:   m.opts.BatesDigits,
+		state:    make(map[string]int),
+		turns:    make([]chan struct{}, projects),
+		released: make([]bool, projects),
+	}
+	for i := range b.turns {
+		b.turns[i] = make(chan struct{})
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, batesStateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read Bates counters: %s", err.Error())
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &b.state); err != nil {
+			return nil, fmt.Errorf("invalid Bates counters %s: %s", filepath.Join(dir, batesStateFile), err.Error())
+		}
+	}
+	if _, ok := b.state[prefix]; !ok {
+		b.state[prefix] = m.opts.BatesStart
+	}
+	return b, nil
+}
+
+// reserve waits for the projects before index to finish and returns the
+// first number of the project. The turn is kept until release, the numbers
+// are only used up by commit.
+func (b *batesCounter) reserve(ctx context.Context, index int) (int, error) {
+	if index > 0 {
+		select {
+		case <-b.turns[index-1]:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state[b.prefix], nil
+}
+
+// commit uses up the numbers of a project whose output is in place
+func (b *batesCounter) commit(n *batesNumbers) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state[b.prefix] = n.next
+}
+
+// release passes the turn on to the next project, also for projects that
+// never reserved any numbers
+func (b *batesCounter) release(index int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.released[index] {
+		close(b.turns[index])
+		b.released[index] = true
+	}
+}
+
+func (b *batesCounter) format(number int) string {
+	return fmt.Sprintf("%s%0*d", b.prefix, b.digits, number)
+}
+
+// mapping returns a row per section of p, stamped from first on
+func (b *batesCounter) mapping(first int, p ProjectPlan, sections []Section) [][]string {
+	// the pages of a table of contents come before the first section and
+	// aren't from any source
+	offset := first - 1
+	rows := [][]string{}
+	for _, section := range sections {
+		rows = append(rows, []string{
+			b.format(offset + section.FirstPage),
+			b.format(offset + section.LastPage),
+			p.Name,
+			p.Output,
+			section.Path,
+			fmt.Sprint(section.FirstPage),
+			fmt.Sprint(section.LastPage),
+		})
+	}
+	return rows
+}
+
+// save writes the counters and appends rows to the mapping
+func (b *batesCounter) save(rows [][]string) error {
+	b.mu.Lock()
+	data, err := json.MarshalIndent(b.state, "", "  ")
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(b.dir, batesStateFile)
+	tmp, err := writeTemp(path, data)
+	if err != nil {
+		return fmt.Errorf("unable to save Bates counters: %s", err.Error())
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to save Bates counters: %s", err.Error())
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := b.appendMapping(rows); err != nil {
+		return fmt.Errorf("unable to write Bates mapping: %s", err.Error())
+	}
+	return nil
+}
+
+func (b *batesCounter) appendMapping(rows [][]string) error {
+	path := filepath.Join(b.dir, batesMappingFile)
+	_, err := os.Stat(path)
+	header := os.IsNotExist(err)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if header {
+		w.Write([]string{"first", "last", "project", "output", "source", "first_page", "last_page"})
+	}
+	w.WriteAll(rows)
+	return w.Error()
+}
+
+// batesWatermark returns the stamp for a single Bates number
+func (m *Merger) batesWatermark(number string) (*model.Watermark, error) {
+	pos := m.opts.BatesPosition
+	dx, dy := 0, 0
+	switch {
+	case strings.HasSuffix(pos, "l"):
+		dx = batesMargin
+	case strings.HasSuffix(pos, "r"):
+		dx = -batesMargin
+	}
+	switch {
+	case strings.HasPrefix(pos, "t"):
+		dy = -batesMargin
+	case strings.HasPrefix(pos, "b"):
+		dy = batesMargin
+	}
+
+	desc := fmt.Sprintf("fontname:%s, points:%d, position:%s, offset:%d %d, scalefactor:1 abs, rotation:0, opacity:1, fillcolor:#000000",
+		m.opts.BatesFont, m.opts.BatesFontSize, pos, dx, dy)
+	return api.TextWatermark(number, desc, true, false, types.POINTS)
+}
+
+// addBates stamps every page of merged with the next Bates numbers
+func (m *Merger) addBates(ctx context.Context, index int, p ProjectPlan, sections []Section, merged []byte) ([]byte, *batesNumbers, error) {
+	pages, err := pageCount(bytes.NewReader(merged))
+	if err != nil {
+		return nil, nil, err
+	}
+	first, err := m.bates.reserve(ctx, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wms := make(map[int]*model.Watermark)
+	for page := 1; page <= pages; page++ {
+		wm, err := m.batesWatermark(m.bates.format(first + page - 1))
+		if err != nil {
+			return nil, nil, err
+		}
+		wms[page] = wm
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	out := &bytes.Buffer{}
+	if err := api.AddWatermarksMap(bytes.NewReader(merged), out, wms, conf); err != nil {
+		return nil, nil, err
+	}
+	m.logger.Info().Msgf("stamped %s to %s on %s", m.bates.format(first), m.bates.format(first+pages-1), p.Output)
+	return out.Bytes(), &batesNumbers{next: first + pages, rows: m.bates.mapping(first, p, sections)}, nil
+}
@@ -0,0 +1,135 @@
+package merger
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// DefaultBookmarkTitle names bookmarks after the source file.
+const DefaultBookmarkTitle = `{{if .Signature}}Signatures {{.Set}}{{else}}{{.Name}}{{end}}`
+
+// BookmarkData is what Options.BookmarkTitle is executed with.
+type BookmarkData struct {
+	// Project is the name of the project.
+	Project string
+	// Path is the path of the source file.
+	Path string
+	// File is the file name of the source.
+	File string
+	// Name is the file name without extension.
+	Name string
+	// Groups are the named groups the group pattern captured from Name.
+	Groups map[string]string
+	// Signature is set for signature sets, with Set being their suffix.
+	Signature bool
+	Set       string
+}
+
+// documents collapses the sections of a signature set made of several files
+// into a single section spanning all of them
+func documents(sections []Section) []Section {
+	docs := []Section{}
+	for i, section := range sections {
+		if i > 0 && section.Signature && sections[i-1].Signature && sections[i-1].Set == section.Set {
+			docs[len(docs)-1].LastPage = section.LastPage
+			continue
+		}
+		docs = append(docs, section)
+	}
+	return docs
+}
+
+// title names a document with the bookmark title template and returns the
+// data it was executed with
+func (m *Merger) title(p ProjectPlan, section Section) (string, BookmarkData, error) {
+	file := filepath.Base(section.Path)
+	data := BookmarkData{
+		Project:   p.Name,
+		Path:      section.Path,
+		File:      file,
+		Name:      strings.TrimSuffix(file, filepath.Ext(file)),
+		Signature: section.Signature,
+		Set:       section.Set,
+	}
+	data.Groups, _ = m.parseGroups(file)
+
+	title := &strings.Builder{}
+	if err := m.bookmarkTitle.Execute(title, data); err != nil {
+		return "", data, err
+	}
+	if title.Len() == 0 {
+		return data.Name, data, nil
+	}
+	return title.String(), data, nil
+}
+
+// bookmarks returns the outline of a merged output, one entry per source
+// document and per signature set, nested under parents when BookmarkGroup
+// is set
+func (m *Merger) bookmarks(p ProjectPlan, sections []Section) ([]pdfcpu.Bookmark, error) {
+	bms := []pdfcpu.Bookmark{}
+	parent := -1
+
+	for _, section := range documents(sections) {
+		title, data, err := m.title(p, section)
+		if err != nil {
+			return nil, err
+		}
+		bm := pdfcpu.Bookmark{Title: title, PageFrom: section.FirstPage}
+
+		if m.opts.BookmarkGroup == "" {
+			bms = append(bms, bm)
+			continue
+		}
+
+		groupTitle := "Signatures"
+		if !section.Signature {
+			groupTitle = data.Groups[m.opts.BookmarkGroup]
+		}
+		if groupTitle == "" {
+			parent = -1
+			bms = append(bms, bm)
+			continue
+		}
+		if parent < 0 || groupTitle != bms[parent].Title {
+			bms = append(bms, pdfcpu.Bookmark{Title: groupTitle, PageFrom: section.FirstPage})
+			parent = len(bms) - 1
+		}
+		bms[parent].Children = append(bms[parent].Children, bm)
+	}
+	return bms, nil
+}
+
+func (m *Merger) addBookmarks(p ProjectPlan, sections []Section, merged []byte) ([]byte, error) {
+	bms, err := m.bookmarks(p, sections)
+	if err != nil {
+		return nil, err
+	}
+	if len(bms) == 0 {
+		return merged, nil
+	}
+
+	// api.AddBookmarks always validates, whatever Options.OutputValidation
+	// says, the output is validated once it is complete
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	conf.Cmd = model.ADDBOOKMARKS
+	ctx, err := api.ReadContext(bytes.NewReader(merged), conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := pdfcpu.AddBookmarks(ctx, bms, true); err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	if err := api.WriteContext(ctx, out); err != nil {
+		return nil, err
+	}
+	m.logger.Info().Msgf("added %d bookmarks to %s", len(bms), p.Output)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package merger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// inputPasswordRule is the password of input files matching a glob
+type inputPasswordRule struct {
+	glob     string
+	password string
+}
+
+// readInputPasswordFile reads GLOB=PASSWORD lines, ignoring empty lines and
+// lines starting with #
+func readInputPasswordFile(file string) ([]inputPasswordRule, error) {
+	kvs, err := readKeyValueFile(file, "GLOB=PASSWORD")
+	if err != nil {
+		return nil, fmt.Errorf("unable to read input password file: %s", err.Error())
+	}
+
+	rules := []inputPasswordRule{}
+	for _, kv := range kvs {
+		if err := checkGlob(kv.key); err != nil {
+			return nil, fmt.Errorf("%s in line %d of input password file %s", err.Error(), kv.line, file)
+		}
+		rules = append(rules, inputPasswordRule{glob: kv.key, password: kv.value})
+	}
+	return rules, nil
+}
+
+// the passwords to try on source, from the first matching rule, the default
+// and the empty password for files only restricted by an owner password
+func (m *Merger) inputPasswords(source Source) []string {
+	candidates := []string{}
+	for _, r := range m.inputPasswordRules {
+		if matchGlob(r.glob, source) {
+			candidates = append(candidates, r.password)
+			break
+		}
+	}
+	if m.opts.InputPassword != "" {
+		candidates = append(candidates, m.opts.InputPassword)
+	}
+	return append(candidates, "")
+}
+
+// errNoPassword is returned for encrypted sources none of the passwords open
+var errNoPassword = errors.New("encrypted and none of the passwords opens it")
+
+// decrypt returns b, the contents of source, decrypted or nil if it isn't
+// encrypted
+func (m *Merger) decrypt(source Source, b []byte) ([]byte, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	ctx, err := api.ReadContext(bytes.NewReader(b), conf)
+	if err == nil && ctx.Encrypt == nil {
+		return nil, nil
+	}
+	if err != nil && !errors.Is(err, pdfcpu.ErrWrongPassword) {
+		return nil, err
+	}
+
+	for _, password := range m.inputPasswords(source) {
+		conf := model.NewDefaultConfiguration()
+		conf.ValidationMode = model.ValidationNone
+		conf.UserPW, conf.OwnerPW = password, password
+		buf := &bytes.Buffer{}
+		err := api.Decrypt(bytes.NewReader(b), buf, conf)
+		if err == nil {
+			m.logger.Info().Msgf("decrypted %s", source.Path)
+			return buf.Bytes(), nil
+		}
+		if !errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return nil, err
+		}
+	}
+	return nil, errNoPassword
+}
@@ -0,0 +1,79 @@
+package merger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hashFile returns the SHA-256 of the contents of path
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dropDuplicates removes the files of a project with the same contents as
+// an earlier one and returns the remaining files and the ones dropped
+func (m *Merger) dropDuplicates(project string, files []string, hashes map[string]string) ([]string, []SkippedFile) {
+	kept := []string{}
+	dropped := []SkippedFile{}
+	first := make(map[string]string)
+	for _, file := range files {
+		hash, ok := hashes[file]
+		if !ok {
+			kept = append(kept, file)
+			continue
+		}
+		if original, ok := first[hash]; ok {
+			m.logger.Warn().Msgf("skipping %s in project %s, it is identical to %s", file, project, original)
+			dropped = append(dropped, SkippedFile{Path: file, Reason: "duplicate of " + original})
+			continue
+		}
+		first[hash] = file
+		kept = append(kept, file)
+	}
+	return kept, dropped
+}
+
+// warnSharedFiles warns about files with the same contents in more than one
+// project, which are merged into each of them
+func (m *Merger) warnSharedFiles(projects []ProjectPlan, hashes map[string]string) {
+	paths := make(map[string][]string)
+	names := make(map[string][]string)
+	for _, p := range projects {
+		seen := make(map[string]bool)
+		for _, source := range p.Sources {
+			hash, ok := hashes[source.Path]
+			if !ok || seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			paths[hash] = append(paths[hash], source.Path)
+			names[hash] = append(names[hash], p.Name)
+		}
+	}
+
+	shared := []string{}
+	for hash, projects := range names {
+		if len(projects) > 1 {
+			shared = append(shared, hash)
+		}
+	}
+	// in the order of the files, not of their hashes
+	sort.Slice(shared, func(i, j int) bool { return paths[shared[i]][0] < paths[shared[j]][0] })
+	for _, hash := range shared {
+		m.logger.Warn().Msgf("the same file is merged into the projects %s: %s",
+			strings.Join(names[hash], ", "), strings.Join(paths[hash], ", "))
+	}
+}
@@ -0,0 +1,83 @@
+package merger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDropDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		hashes  map[string]string
+		kept    []string
+		dropped []SkippedFile
+	}{
+		{
+			name:    "no duplicates",
+			files:   []string{"a.pdf", "b.pdf"},
+			hashes:  map[string]string{"a.pdf": "1", "b.pdf": "2"},
+			kept:    []string{"a.pdf", "b.pdf"},
+			dropped: []SkippedFile{},
+		},
+		{
+			name:   "later copies are dropped",
+			files:  []string{"a.pdf", "b.pdf", "c.pdf", "d.pdf"},
+			hashes: map[string]string{"a.pdf": "1", "b.pdf": "2", "c.pdf": "1", "d.pdf": "1"},
+			kept:   []string{"a.pdf", "b.pdf"},
+			dropped: []SkippedFile{
+				{Path: "c.pdf", Reason: "duplicate of a.pdf"},
+				{Path: "d.pdf", Reason: "duplicate of a.pdf"},
+			},
+		},
+		{
+			name:    "files without a hash are kept",
+			files:   []string{"a.pdf", "b.pdf", "c.pdf"},
+			hashes:  map[string]string{"a.pdf": "1"},
+			kept:    []string{"a.pdf", "b.pdf", "c.pdf"},
+			dropped: []SkippedFile{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestMerger(t, Options{})
+			kept, dropped := m.dropDuplicates("T", test.files, test.hashes)
+			if !reflect.DeepEqual(kept, test.kept) {
+				t.Errorf("got kept %v, want %v", kept, test.kept)
+			}
+			if !reflect.DeepEqual(dropped, test.dropped) {
+				t.Errorf("got dropped %+v, want %+v", dropped, test.dropped)
+			}
+		})
+	}
+}
+
+func TestPlanKeepsOriginalOverCopy(t *testing.T) {
+	in := t.TempDir()
+	for _, name := range []string{"T_01-2 (1).pdf", "T_01-2.pdf"} {
+		if err := os.WriteFile(filepath.Join(in, name), []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, order := range []string{"natural", "seq"} {
+		t.Run(order, func(t *testing.T) {
+			m := newTestMerger(t, Options{InputDir: in, Order: order})
+			plan, err := m.Plan(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := plan.Projects[0]
+			if want := []Source{{Path: filepath.Join(in, "T_01-2.pdf")}}; !reflect.DeepEqual(p.Sources, want) {
+				t.Errorf("got sources %+v, want %+v", p.Sources, want)
+			}
+			want := []SkippedFile{{Path: filepath.Join(in, "T_01-2 (1).pdf"), Reason: "duplicate of " + filepath.Join(in, "T_01-2.pdf")}}
+			if !reflect.DeepEqual(p.Skipped, want) {
+				t.Errorf("got skipped %+v, want %+v", p.Skipped, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// padToEven appends a blank page of the size of the last page to r if it has
+// an odd number of pages, so the next document starts on a right-hand page
+// when printed double-sided. It returns the number of pages added.
+func (m *Merger) padToEven(source Source, r io.ReadSeeker) (io.ReadSeeker, int, error) {
+	pages, err := pageCount(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	if pages%2 == 0 {
+		return r, 0, nil
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	buf := &bytes.Buffer{}
+	if err := api.InsertPages(r, buf, []string{fmt.Sprint(pages)}, false, conf); err != nil {
+		return nil, 0, err
+	}
+
+	if m.opts.BlankPageText != "" {
+		wm, err := api.TextWatermark(m.opts.BlankPageText, "fontname:Helvetica, points:12, position:c, scalefactor:1 abs, rotation:0, opacity:1, fillcolor:#808080", true, false, types.POINTS)
+		if err != nil {
+			return nil, 0, err
+		}
+		stamped := &bytes.Buffer{}
+		if err := api.AddWatermarksMap(bytes.NewReader(buf.Bytes()), stamped, map[int]*model.Watermark{pages + 1: wm}, conf); err != nil {
+			return nil, 0, err
+		}
+		buf = stamped
+	}
+
+	m.logger.Info().Msgf("added a blank page after the %d pages of %s", pages, source.Path)
+	return bytes.NewReader(buf.Bytes()), 1, nil
+}
@@ -0,0 +1,186 @@
+package merger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// permission presets for encrypted outputs
+var permissionPresets = map[string]int16{
+	"none":  model.PermissionsNone,
+	"print": model.PermissionsPrint,
+	"all":   model.PermissionsAll,
+}
+
+// Passwords a single output is encrypted with. Owner defaults to User.
+type Passwords struct {
+	User  string
+	Owner string
+}
+
+func (p Passwords) empty() bool {
+	return p.User == "" && p.Owner == ""
+}
+
+// owner returns the owner password the output is encrypted with
+func (p Passwords) owner() string {
+	if p.Owner == "" {
+		return p.User
+	}
+	return p.Owner
+}
+
+// open lets conf read outputs encrypted with p
+func (p Passwords) open(conf *model.Configuration) {
+	conf.UserPW = p.User
+	conf.OwnerPW = p.owner()
+}
+
+// ReadPasswords returns the passwords in file if it is given, or else user
+// and owner, to open encrypted outputs with.
+func ReadPasswords(user, owner, file string) (Passwords, error) {
+	if file != "" {
+		return readPasswordFile(file)
+	}
+	return Passwords{User: user, Owner: owner}, nil
+}
+
+// readPasswordFile reads user=PASSWORD and owner=PASSWORD lines, ignoring
+// empty lines and lines starting with #
+func readPasswordFile(path string) (Passwords, error) {
+	const format = "user=PASSWORD or owner=PASSWORD"
+	kvs, err := readKeyValueFile(path, format)
+	if err != nil {
+		return Passwords{}, fmt.Errorf("unable to read password file: %s", err.Error())
+	}
+
+	pw := Passwords{}
+	for _, kv := range kvs {
+		switch kv.key {
+		case "user":
+			pw.User = kv.value
+		case "owner":
+			pw.Owner = kv.value
+		default:
+			return Passwords{}, fmt.Errorf("invalid line %d in password file %s, must be %s", kv.line, path, format)
+		}
+	}
+	if pw.empty() {
+		return Passwords{}, fmt.Errorf("no passwords in password file %s", path)
+	}
+	return pw, nil
+}
+
+// readPasswordCSV reads a CSV with a header row with the columns project,
+// user_password and owner_password
+func readPasswordCSV(path string) (map[string]Passwords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read password CSV: %s", err.Error())
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read password CSV %s: %s", path, err.Error())
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["project"]; !ok {
+		return nil, fmt.Errorf("password CSV %s is missing the column \"project\"", path)
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	projects := make(map[string]Passwords)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read password CSV %s: %s", path, err.Error())
+		}
+		pw := Passwords{User: field(record, "user_password"), Owner: field(record, "owner_password")}
+		project := strings.TrimSpace(field(record, "project"))
+		if pw.empty() {
+			return nil, fmt.Errorf("no passwords for project %s in password CSV %s", project, path)
+		}
+		projects[project] = pw
+	}
+	return projects, nil
+}
+
+// loadPasswords reads the configured password sources, failing if any of them
+// is missing so nothing is written in the clear by accident
+func (m *Merger) loadPasswords() error {
+	m.defaultPasswords = Passwords{User: m.opts.UserPassword, Owner: m.opts.OwnerPassword}
+	if m.opts.PasswordFile != "" {
+		pw, err := readPasswordFile(m.opts.PasswordFile)
+		if err != nil {
+			return err
+		}
+		m.defaultPasswords = pw
+	}
+	if m.opts.PasswordCSV != "" {
+		projects, err := readPasswordCSV(m.opts.PasswordCSV)
+		if err != nil {
+			return err
+		}
+		m.projectPasswords = projects
+	}
+	if m.defaultPasswords.empty() && m.projectPasswords == nil {
+		return errors.New("encryption needs passwords from the environment, a password file or a password CSV")
+	}
+	return nil
+}
+
+// the passwords for a project, from the password CSV or else the defaults
+func (m *Merger) passwordsFor(project string) (Passwords, error) {
+	if pw, ok := m.projectPasswords[project]; ok {
+		return pw, nil
+	}
+	if m.defaultPasswords.empty() {
+		return Passwords{}, fmt.Errorf("no passwords for project %s", project)
+	}
+	return m.defaultPasswords, nil
+}
+
+// encryptionConf returns the configuration project is encrypted and read
+// back with
+func (m *Merger) encryptionConf(project string) (*model.Configuration, error) {
+	pw, err := m.passwordsFor(project)
+	if err != nil {
+		return nil, err
+	}
+	conf := model.NewAESConfiguration(pw.User, pw.owner(), m.opts.EncryptKeyLength)
+	conf.Permissions = permissionPresets[m.opts.Permissions]
+	return conf, nil
+}
+
+// encrypt encrypts merged with AES and restricts its permissions
+func (m *Merger) encrypt(p ProjectPlan, conf *model.Configuration, merged []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+	if err := api.Encrypt(bytes.NewReader(merged), out, conf); err != nil {
+		return nil, err
+	}
+	m.logger.Info().Msgf("encrypted %s with %d bit AES, permissions %s", p.Output, m.opts.EncryptKeyLength, m.opts.Permissions)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,44 @@
+package merger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewPasswordFilesNeedEncrypt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "passwords")
+	if err := os.WriteFile(file, []byte("user=u\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	csv := filepath.Join(t.TempDir(), "passwords.csv")
+	if err := os.WriteFile(csv, []byte("project,user_password,owner_password\nT,u,o\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		ok   bool
+	}{
+		{"password file without encrypt", Options{PasswordFile: file}, false},
+		{"password csv without encrypt", Options{PasswordCSV: csv}, false},
+		{"password file with encrypt", Options{PasswordFile: file, Encrypt: true}, true},
+		{"password csv with encrypt", Options{PasswordCSV: csv, Encrypt: true}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.InputDir, opts.OutputDir = t.TempDir(), t.TempDir()
+			_, err := New(opts, zerolog.Nop())
+			if test.ok && err != nil {
+				t.Errorf("got error %s", err)
+			}
+			if !test.ok && err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package merger
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// FileInfo is the page count and document metadata of a PDF file.
+type FileInfo struct {
+	Path     string `json:"path"`
+	Pages    int    `json:"pages"`
+	Version  string `json:"version"`
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Creator  string `json:"creator,omitempty"`
+	Producer string `json:"producer,omitempty"`
+	Created  string `json:"created,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// Inspect reads the page count and metadata of a PDF file with api.Info.
+func Inspect(path string) (FileInfo, error) {
+	info := FileInfo{Path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	lines, err := api.Info(f, nil, conf)
+	if err != nil {
+		return info, err
+	}
+
+	for _, line := range lines {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Page count":
+			info.Pages, _ = strconv.Atoi(value)
+		case "PDF version":
+			info.Version = value
+		case "Title":
+			info.Title = value
+		case "Author":
+			info.Author = value
+		case "Subject":
+			info.Subject = value
+		case "Content creator":
+			info.Creator = value
+		case "PDF Producer":
+			info.Producer = value
+		case "Creation date":
+			info.Created = value
+		case "Modification date":
+			info.Modified = value
+		}
+	}
+	return info, nil
+}
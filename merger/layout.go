@@ -0,0 +1,94 @@
+package merger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Section is the page range a single source ended up at in a merged output.
+type Section struct {
+	Path      string `json:"path"`
+	Signature bool   `json:"signature,omitempty"`
+	Set       string `json:"set,omitempty"`
+	FirstPage int    `json:"firstPage"`
+	LastPage  int    `json:"lastPage"`
+}
+
+// Layout records where every source of a merged output starts and ends. It
+// is written next to the output so the output can be split again later.
+type Layout struct {
+	Project  string    `json:"project"`
+	Output   string    `json:"output"`
+	Sections []Section `json:"sections"`
+}
+
+// LayoutPath returns the layout file belonging to a merged output, the
+// output path with .layout.json instead of .pdf.
+func LayoutPath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".layout.json"
+}
+
+// writeLayout writes layout to a temporary file next to its layout file. The
+// caller renames it to LayoutPath or removes it.
+func writeLayout(layout Layout) (string, error) {
+	b, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return writeTemp(LayoutPath(layout.Output), b)
+}
+
+// ReadLayout reads the layout written when output was merged.
+func ReadLayout(output string) (*Layout, error) {
+	b, err := os.ReadFile(LayoutPath(output))
+	if err != nil {
+		return nil, fmt.Errorf("no layout for %s, was it merged by pdfmerger? %s", output, err.Error())
+	}
+	layout := &Layout{}
+	if err := json.Unmarshal(b, layout); err != nil {
+		return nil, fmt.Errorf("invalid layout %s: %s", LayoutPath(output), err.Error())
+	}
+	return layout, nil
+}
+
+// Split writes every section of a merged output into its own file in dir,
+// named after the original source, and returns the written files. An
+// encrypted output is opened with pw.
+func Split(output, dir string, pw Passwords) ([]string, error) {
+	layout, err := ReadLayout(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	written := []string{}
+	used := make(map[string]int)
+	for _, section := range layout.Sections {
+		name := filepath.Base(section.Path)
+		used[name]++
+		if used[name] > 1 {
+			ext := filepath.Ext(name)
+			name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), used[name], ext)
+		}
+		file := filepath.Join(dir, name)
+
+		conf := model.NewDefaultConfiguration()
+		conf.ValidationMode = model.ValidationNone
+		pw.open(conf)
+		pages := []string{fmt.Sprintf("%d-%d", section.FirstPage, section.LastPage)}
+		if err := api.TrimFile(output, file, pages, conf); err != nil {
+			return written, fmt.Errorf("unable to write pages %s of %s to %s: %s", pages[0], output, file, err.Error())
+		}
+		written = append(written, file)
+	}
+	return written, nil
+}
@@ -0,0 +1,234 @@
+package merger
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"gopkg.in/yaml.v2"
+)
+
+// manifest lists every output project with an explicit, ordered list of
+// source files, replacing the directory scan and file name heuristics
+type manifest struct {
+	Projects []manifestProject `yaml:"projects" json:"projects"`
+}
+
+type manifestProject struct {
+	Name    string           `yaml:"name" json:"name"`
+	Output  string           `yaml:"output" json:"output"`
+	Sources []manifestSource `yaml:"sources" json:"sources"`
+}
+
+type manifestSource struct {
+	File  string `yaml:"file" json:"file"`
+	Pages string `yaml:"pages" json:"pages"`
+}
+
+// read a manifest from a .yaml, .yml, .json or .csv file
+func loadManifest(path, outputDir string) (*manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &manifest{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(f).Decode(m)
+	case ".json":
+		err = json.NewDecoder(f).Decode(m)
+	case ".csv":
+		m, err = readCSVManifest(f)
+	default:
+		return nil, fmt.Errorf("unknown manifest format %q, must be .yaml, .yml, .json or .csv", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest %s: %s", path, err.Error())
+	}
+
+	if err := m.resolve(filepath.Dir(path), outputDir); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %s", path, err.Error())
+	}
+	return m, nil
+}
+
+// a CSV manifest has a header row with the columns project and file, and
+// optionally output and pages. Rows of the same project are merged in the
+// order they appear.
+func readCSVManifest(r io.Reader) (*manifest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"project", "file"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	column := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	m := &manifest{}
+	index := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := column(record, "project")
+		i, ok := index[name]
+		if !ok {
+			i = len(m.Projects)
+			index[name] = i
+			m.Projects = append(m.Projects, manifestProject{Name: name})
+		}
+		if output := column(record, "output"); output != "" {
+			m.Projects[i].Output = output
+		}
+		m.Projects[i].Sources = append(m.Projects[i].Sources, manifestSource{
+			File:  column(record, "file"),
+			Pages: column(record, "pages"),
+		})
+	}
+	return m, nil
+}
+
+// check every project and make relative source paths relative to the
+// manifest directory and relative output paths relative to the output
+// directory
+func (m *manifest) resolve(baseDir, outputDir string) error {
+	if len(m.Projects) == 0 {
+		return errors.New("no projects")
+	}
+
+	seen := make(map[string]bool)
+	for i := range m.Projects {
+		p := &m.Projects[i]
+		if p.Name == "" {
+			return fmt.Errorf("project %d has no name", i+1)
+		}
+		if p.Output == "" {
+			p.Output = p.Name + ".pdf"
+		}
+		if !filepath.IsAbs(p.Output) {
+			p.Output = filepath.Join(outputDir, p.Output)
+		}
+		if seen[p.Output] {
+			return fmt.Errorf("output %s is used by more than one project", p.Output)
+		}
+		seen[p.Output] = true
+
+		if len(p.Sources) == 0 {
+			return fmt.Errorf("project %s has no sources", p.Name)
+		}
+		for j := range p.Sources {
+			s := &p.Sources[j]
+			if s.File == "" {
+				return fmt.Errorf("project %s source %d has no file", p.Name, j+1)
+			}
+			if !filepath.IsAbs(s.File) {
+				s.File = filepath.Join(baseDir, s.File)
+			}
+			if _, err := s.pageSelection(); err != nil {
+				return fmt.Errorf("project %s source %s: %s", p.Name, s.File, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (s manifestSource) pageSelection() ([]string, error) {
+	if s.Pages == "" {
+		return nil, nil
+	}
+	return api.ParsePageSelection(s.Pages)
+}
+
+// planFromManifest builds the merge plan straight from the manifest instead
+// of scanning the input directory
+func (m *Merger) planFromManifest() (*Plan, error) {
+	man, err := loadManifest(m.opts.Manifest, m.opts.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	for _, p := range man.Projects {
+		project := ProjectPlan{
+			Name:   p.Name,
+			Output: p.Output,
+			Order:  "manifest",
+		}
+		for _, s := range p.Sources {
+			reason, err := m.checkManifestSource(s.File)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				m.logger.Warn().Msgf("skipping %s in project %s: %s", s.File, p.Name, reason)
+				project.Skipped = append(project.Skipped, SkippedFile{Path: s.File, Reason: reason})
+				continue
+			}
+			pages, _ := s.pageSelection()
+			project.Sources = append(project.Sources, Source{Path: s.File, Pages: pages})
+		}
+		plan.Projects = append(plan.Projects, project)
+	}
+	return plan, nil
+}
+
+// checkManifestSource applies the checks of a directory scan to a source
+// listed in the manifest and returns why it has to be skipped, or an empty
+// reason if it can be merged
+func (m *Merger) checkManifestSource(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return "file not found", nil
+	}
+	if err != nil {
+		return "unable to read file: " + err.Error(), nil
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch m.opts.Symlinks {
+		case "skip":
+			return "symlink", nil
+		case "error":
+			return "", fmt.Errorf("symlinks are not allowed in the manifest: %s", path)
+		}
+		if info, err = os.Stat(path); err != nil {
+			return "broken symlink: " + err.Error(), nil
+		}
+	}
+	if info.IsDir() {
+		return "directory", nil
+	}
+	if filepath.Ext(path) != ".pdf" {
+		return "not a pdf file", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,169 @@
+package merger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSVManifest(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []manifestProject
+		err  string
+	}{
+		{
+			name: "rows of a project in order",
+			csv:  "project,file\nA,a1.pdf\nB,b1.pdf\nA,a2.pdf\n",
+			want: []manifestProject{
+				{Name: "A", Sources: []manifestSource{{File: "a1.pdf"}, {File: "a2.pdf"}}},
+				{Name: "B", Sources: []manifestSource{{File: "b1.pdf"}}},
+			},
+		},
+		{
+			name: "optional columns in any order and case",
+			csv:  "Pages, File ,OUTPUT,project\n1-2, a.pdf,out/a.pdf,A\n,b.pdf,,A\n",
+			want: []manifestProject{
+				{Name: "A", Output: "out/a.pdf", Sources: []manifestSource{{File: "a.pdf", Pages: "1-2"}, {File: "b.pdf"}}},
+			},
+		},
+		{
+			name: "missing file column",
+			csv:  "project,pages\nA,1\n",
+			err:  `missing column "file"`,
+		},
+		{
+			name: "missing project column",
+			csv:  "file\na.pdf\n",
+			err:  `missing column "project"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := readCSVManifest(strings.NewReader(test.csv))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m.Projects, test.want) {
+				t.Errorf("got %+v, want %+v", m.Projects, test.want)
+			}
+		})
+	}
+}
+
+func TestManifestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		projects []manifestProject
+		want     []manifestProject
+		err      string
+	}{
+		{
+			name: "relative paths",
+			projects: []manifestProject{
+				{Name: "A", Sources: []manifestSource{{File: "a.pdf"}, {File: "/abs/b.pdf", Pages: "1,3-4"}}},
+				{Name: "B", Output: "sub/b-out.pdf", Sources: []manifestSource{{File: "dir/c.pdf"}}},
+				{Name: "C", Output: "/abs/c.pdf", Sources: []manifestSource{{File: "c.pdf"}}},
+			},
+			want: []manifestProject{
+				{Name: "A", Output: "/out/A.pdf", Sources: []manifestSource{{File: "/base/a.pdf"}, {File: "/abs/b.pdf", Pages: "1,3-4"}}},
+				{Name: "B", Output: "/out/sub/b-out.pdf", Sources: []manifestSource{{File: "/base/dir/c.pdf"}}},
+				{Name: "C", Output: "/abs/c.pdf", Sources: []manifestSource{{File: "/base/c.pdf"}}},
+			},
+		},
+		{
+			name: "no projects",
+			err:  "no projects",
+		},
+		{
+			name:     "no name",
+			projects: []manifestProject{{Sources: []manifestSource{{File: "a.pdf"}}}},
+			err:      "project 1 has no name",
+		},
+		{
+			name:     "no sources",
+			projects: []manifestProject{{Name: "A"}},
+			err:      "project A has no sources",
+		},
+		{
+			name:     "source without file",
+			projects: []manifestProject{{Name: "A", Sources: []manifestSource{{Pages: "1"}}}},
+			err:      "project A source 1 has no file",
+		},
+		{
+			name: "shared output",
+			projects: []manifestProject{
+				{Name: "A", Output: "x.pdf", Sources: []manifestSource{{File: "a.pdf"}}},
+				{Name: "B", Output: "x.pdf", Sources: []manifestSource{{File: "b.pdf"}}},
+			},
+			err: "output /out/x.pdf is used by more than one project",
+		},
+		{
+			name:     "invalid pages",
+			projects: []manifestProject{{Name: "A", Sources: []manifestSource{{File: "a.pdf", Pages: "x"}}}},
+			err:      "project A source /base/a.pdf",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &manifest{Projects: test.projects}
+			err := m.resolve("/base", "/out")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m.Projects, test.want) {
+				t.Errorf("got %+v, want %+v", m.Projects, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanFromManifestSkipsUnusableSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.pdf", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.pdf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifestFile := filepath.Join(dir, "manifest.csv")
+	csv := "project,file\nA,a.pdf\nA,missing.pdf\nA,notes.txt\nA,sub.pdf\n"
+	if err := os.WriteFile(manifestFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestMerger(t, Options{Manifest: manifestFile})
+	plan, err := m.planFromManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := plan.Projects[0]
+	if want := []Source{{Path: filepath.Join(dir, "a.pdf")}}; !reflect.DeepEqual(p.Sources, want) {
+		t.Errorf("got sources %+v, want %+v", p.Sources, want)
+	}
+	want := []SkippedFile{
+		{Path: filepath.Join(dir, "missing.pdf"), Reason: "file not found"},
+		{Path: filepath.Join(dir, "notes.txt"), Reason: "not a pdf file"},
+		{Path: filepath.Join(dir, "sub.pdf"), Reason: "directory"},
+	}
+	if !reflect.DeepEqual(p.Skipped, want) {
+		t.Errorf("got skipped %+v, want %+v", p.Skipped, want)
+	}
+}
@@ -0,0 +1,253 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// mergePDF merges the sources of the project at index of the plan into its
+// output and returns the page range every source ended up at and what was
+// changed on the way
+func (m *Merger) mergePDF(ctx context.Context, index int, p ProjectPlan) (Result, error) {
+	result := Result{}
+
+	m.logger.Info().Msgf("order of merging into project %s (order: %s):", p.Name, p.Order)
+	for _, source := range p.Sources {
+		if len(source.Pages) > 0 {
+			m.logger.Info().Msgf("%s (pages %s)", source.Path, strings.Join(source.Pages, ","))
+		} else {
+			m.logger.Info().Msgf(source.Path)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.Output), 0755); err != nil {
+		return result, err
+	}
+
+	mergeConf := model.NewDefaultConfiguration()
+	mergeConf.ValidationMode = model.ValidationNone
+
+	// resolved before any work so a project without passwords fails
+	// before merging anything
+	var encryptConf *model.Configuration
+	validateConf := m.validationConf(m.opts.OutputValidation)
+	if m.opts.Encrypt {
+		var err error
+		if encryptConf, err = m.encryptionConf(p.Name); err != nil {
+			return result, err
+		}
+		validateConf.UserPW = encryptConf.UserPW
+		validateConf.OwnerPW = encryptConf.OwnerPW
+	}
+
+	sources, decrypted, err := m.prepareSources(p, &result)
+	if err != nil {
+		return result, err
+	}
+	p.Sources = sources
+
+	readers := []io.ReadSeeker{}
+	blanks := []int{}
+	for i, source := range p.Sources {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var r io.ReadSeeker
+		if b, ok := decrypted[source.Path]; ok {
+			r = bytes.NewReader(b)
+		} else {
+			f, err := os.Open(source.Path)
+			if err != nil {
+				return result, err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		if len(source.Pages) > 0 {
+			trimConf := model.NewDefaultConfiguration()
+			trimConf.ValidationMode = model.ValidationNone
+			buf := &bytes.Buffer{}
+			if err := api.Trim(r, buf, source.Pages, trimConf); err != nil {
+				return result, fmt.Errorf("unable to select pages %s of %s: %s", strings.Join(source.Pages, ","), source.Path, err.Error())
+			}
+			r = bytes.NewReader(buf.Bytes())
+		}
+
+		if orientation := m.sourceOrientation(source); orientation != "" && orientation != "none" {
+			if r, err = m.rotatePages(source, r, orientation); err != nil {
+				return result, fmt.Errorf("unable to rotate %s to %s: %s", source.Path, orientation, err.Error())
+			}
+		}
+
+		if size := m.sourcePageSize(source); size != nil {
+			resized, scaled, err := resizePages(source, r, size)
+			if err != nil {
+				return result, fmt.Errorf("unable to resize %s to %s: %s", source.Path, size.name, err.Error())
+			}
+			for _, s := range scaled {
+				m.logger.Info().Msgf("scaled pages %d-%d of %s from %.0fx%.0f by %.4g to %s %s", s.FirstPage, s.LastPage, s.Path, s.Width, s.Height, s.Scale, s.Policy, s.Size)
+			}
+			r = resized
+			result.Scaled = append(result.Scaled, scaled...)
+		}
+
+		blank := 0
+		if m.opts.Duplex && i < len(p.Sources)-1 {
+			if r, blank, err = m.padToEven(source, r); err != nil {
+				return result, fmt.Errorf("unable to add a blank page after %s: %s", source.Path, err.Error())
+			}
+		}
+		readers = append(readers, r)
+		blanks = append(blanks, blank)
+	}
+
+	sections, err := pageSections(p.Sources, readers, blanks)
+	if err != nil {
+		return result, err
+	}
+
+	var toc []tocEntry
+	if m.opts.TableOfContents && len(sections) > 0 {
+		var tocPages []byte
+		if tocPages, toc, err = m.tableOfContents(p, sections); err != nil {
+			return result, fmt.Errorf("unable to create table of contents of %s: %s", p.Output, err.Error())
+		}
+		readers = append([]io.ReadSeeker{bytes.NewReader(tocPages)}, readers...)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := api.MergeRaw(readers, buf, mergeConf); err != nil {
+		return result, err
+	}
+	merged := buf.Bytes()
+
+	if len(toc) > 0 {
+		if merged, err = m.addTOCLinks(p, toc, merged); err != nil {
+			return result, fmt.Errorf("unable to link table of contents of %s: %s", p.Output, err.Error())
+		}
+	}
+
+	if m.opts.Bookmarks {
+		if merged, err = m.addBookmarks(p, sections, merged); err != nil {
+			return result, fmt.Errorf("unable to add bookmarks to %s: %s", p.Output, err.Error())
+		}
+	}
+
+	if len(m.properties) > 0 || m.opts.SourceProperties {
+		if merged, err = m.addProperties(p, merged); err != nil {
+			return result, fmt.Errorf("unable to set document properties of %s: %s", p.Output, err.Error())
+		}
+	}
+
+	if m.opts.AttachSources {
+		if merged, err = m.addSources(p, merged); err != nil {
+			return result, fmt.Errorf("unable to attach sources to %s: %s", p.Output, err.Error())
+		}
+	}
+
+	var bates *batesNumbers
+	if m.bates != nil {
+		if merged, bates, err = m.addBates(ctx, index, p, sections, merged); err != nil {
+			return result, fmt.Errorf("unable to add Bates numbers to %s: %s", p.Output, err.Error())
+		}
+	}
+
+	if encryptConf != nil {
+		if merged, err = m.encrypt(p, encryptConf, merged); err != nil {
+			return result, fmt.Errorf("unable to encrypt %s: %s", p.Output, err.Error())
+		}
+	}
+
+	// the output is only replaced once the new one is complete and valid
+	tmp, err := writeTemp(p.Output, merged)
+	if err != nil {
+		return result, err
+	}
+	defer os.Remove(tmp)
+
+	validation := Validation{Path: p.Output, Mode: m.opts.OutputValidation}
+	if err := api.ValidateFile(tmp, validateConf); err != nil {
+		validation.Err = strings.TrimSpace(err.Error())
+		result.Validations = append(result.Validations, validation)
+		return result, err
+	}
+	result.Validations = append(result.Validations, validation)
+	if m.opts.OutputValidation != "none" {
+		m.logger.Info().Msgf("successfully validated file: %s (%s)", p.Output, m.opts.OutputValidation)
+	}
+
+	layoutTmp, err := writeLayout(Layout{Project: p.Name, Output: p.Output, Sections: sections})
+	if err != nil {
+		return result, fmt.Errorf("unable to write layout of %s: %s", p.Output, err.Error())
+	}
+	defer os.Remove(layoutTmp)
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	// the layout goes first, so an output is never next to the layout of an
+	// earlier build
+	if err := os.Rename(layoutTmp, LayoutPath(p.Output)); err != nil {
+		return result, fmt.Errorf("unable to write layout of %s: %s", p.Output, err.Error())
+	}
+	if err := os.Rename(tmp, p.Output); err != nil {
+		return result, err
+	}
+	if bates != nil {
+		m.bates.commit(bates)
+		result.bates = bates
+	}
+	result.Sections = sections
+	return result, nil
+}
+
+// pageSections counts the pages of every source to work out where it will
+// start and end in the merged output, leaving out the blank pages added
+// after it
+func pageSections(sources []Source, readers []io.ReadSeeker, blanks []int) ([]Section, error) {
+	sections := []Section{}
+	page := 1
+	for i, r := range readers {
+		count, err := pageCount(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to count pages of %s: %s", sources[i].Path, err.Error())
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		sections = append(sections, Section{
+			Path:      sources[i].Path,
+			Signature: sources[i].Signature,
+			Set:       sources[i].Set,
+			FirstPage: page,
+			LastPage:  page + count - 1 - blanks[i],
+		})
+		page += count
+	}
+	return sections, nil
+}
+
+// pageCount reads the page count without validating, unlike api.PageCount
+func pageCount(r io.ReadSeeker) (int, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	ctx, err := api.ReadContext(r, conf)
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return 0, err
+	}
+	return ctx.PageCount, nil
+}
@@ -0,0 +1,626 @@
+// Package merger groups a directory of PDF files into projects and merges
+// every project into a single PDF file.
+package merger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/rs/zerolog"
+)
+
+// DefaultGroupPattern keeps the original PROJECT-SUFFIX.pdf naming convention,
+// where everything before the first dash is the project and the next dash
+// separated part is the document suffix used for ordering and signatures.
+const DefaultGroupPattern = `^(?P<project>[^-]+)-(?P<seq>[^-]+)`
+
+// Options configures a Merger. Empty fields fall back to the same defaults
+// as the command line flags.
+type Options struct {
+	// InputDir is scanned for PDF files unless Manifest is set.
+	InputDir string
+	// OutputDir receives one merged PDF file per project.
+	OutputDir string
+	// GroupPattern is matched against file names without extension, the
+	// named group "project" picks the project and "seq" the document suffix.
+	GroupPattern string
+	// Recursive is empty to only read InputDir itself, "flatten" to group
+	// the whole tree by file name or "directory" to make every
+	// subdirectory a project.
+	Recursive string
+	// Symlinks is "follow", "skip" or "error".
+	Symlinks string
+	// Order is one of "natural", "seq", "mtime" or "created".
+	Order string
+	// Manifest is a YAML, JSON or CSV file listing the projects to merge
+	// instead of scanning InputDir.
+	Manifest string
+	// Jobs is the number of projects merged in parallel, at least 1.
+	Jobs int
+	// FailFast stops starting new projects after the first failure.
+	FailFast bool
+	// SignatureDir holds the signature-SUFFIX.pdf files, defaults to
+	// OutputDir. Files in it are never merged as project inputs.
+	SignatureDir string
+	// SignatureRules place signature sets as SET=PLACEMENT[@PROJECT], where
+	// SET is a glob over the suffix, PLACEMENT is "after" or "before" every
+	// document with that suffix, "end" of the project or "none", and
+	// PROJECT is a regexp limiting the rule to matching projects. The first
+	// matching rule wins, sets without one go "after".
+	SignatureRules []string
+	// Bookmarks adds an outline entry for every source document and every
+	// signature set pointing at its first page.
+	Bookmarks bool
+	// BookmarkTitle is a text/template for the bookmark titles, see
+	// BookmarkData for the available fields. Defaults to DefaultBookmarkTitle.
+	BookmarkTitle string
+	// BookmarkGroup nests the bookmarks of consecutive documents under a
+	// parent named after the value of this captured group, for example a
+	// document type. Signature sets go under a "Signatures" parent.
+	BookmarkGroup string
+	// TableOfContents prepends pages listing every source document and
+	// signature set with its page range, each line linking to its first
+	// page. Lines are named like bookmarks.
+	TableOfContents bool
+	// BatesPrefix stamps every page of every output with a Bates number,
+	// this prefix followed by a zero padded counter that continues across
+	// projects in plan order and across runs. The counters are kept in
+	// OutputDir, next to bates.csv mapping every range to its source.
+	// Empty disables Bates numbering.
+	BatesPrefix string
+	// BatesDigits is the width of the counter, defaults to 6.
+	BatesDigits int
+	// BatesStart is the first number of a prefix no earlier run used,
+	// defaults to 1.
+	BatesStart int
+	// BatesFont and BatesFontSize pick one of the core PDF fonts, defaults
+	// to Helvetica at 9 points.
+	BatesFont     string
+	BatesFontSize int
+	// BatesPosition anchors the number on the page, one of tl, tc, tr, l,
+	// c, r, bl, bc or br, defaults to br.
+	BatesPosition string
+	// Title, Author, Subject, Creator and Keywords are text/templates for
+	// the document properties of every output, see PropertyData for the
+	// available fields. Empty ones keep what the first source had.
+	Title    string
+	Author   string
+	Subject  string
+	Creator  string
+	Keywords string
+	// SourceProperties adds the custom properties Project, SourceCount and
+	// Source1 to SourceN with the file names of the merged sources.
+	SourceProperties bool
+	// AttachSources attaches the original file of every source to its
+	// output, next to a SourcesManifest with their sizes and hashes, so
+	// ExtractSources can recover them.
+	AttachSources bool
+	// PageSize brings every page to a paper size such as A4 or Letter, or
+	// WIDTHxHEIGHT in points, turned to the orientation of the page. An
+	// optional :POLICY suffix overrides PageSizePolicy. Empty leaves pages
+	// alone.
+	PageSize string
+	// PageSizePolicy is "fit" to scale pages down or up until they fit,
+	// "fill" to scale them until they cover the page size and crop the
+	// rest, or "center" to only pad or crop them. Defaults to "fit".
+	PageSizePolicy string
+	// PageSizeRules override PageSize as GLOB=SIZE[:POLICY] or GLOB=none,
+	// where GLOB is matched against the file name of a source. The first
+	// matching rule wins.
+	PageSizeRules []string
+	// Orientation is "portrait" or "landscape" to rotate every page of the
+	// other orientation by RotateDegrees before merging. Empty or "none"
+	// leaves pages alone.
+	Orientation string
+	// OrientationRules override Orientation as GLOB=ORIENTATION, where GLOB
+	// is matched against the file name of a source. The first matching rule
+	// wins.
+	OrientationRules []string
+	// RotateDegrees is 90 to rotate clockwise or -90 counterclockwise,
+	// defaults to 90.
+	RotateDegrees int
+	// Duplex adds a blank page after every source with an odd number of
+	// pages but the last, and after an odd table of contents, so every
+	// document starts on a right-hand page when printed double-sided.
+	Duplex bool
+	// BlankPageText is stamped on the blank pages added for Duplex, such
+	// as "This page intentionally left blank".
+	BlankPageText string
+	// Encrypt protects every output with AES and restricts its
+	// permissions. The passwords come from UserPassword and OwnerPassword,
+	// PasswordFile or PasswordCSV and a project without any fails.
+	Encrypt bool
+	// UserPassword opens the outputs and OwnerPassword lifts the
+	// permission restrictions, it defaults to UserPassword.
+	UserPassword  string
+	OwnerPassword string
+	// PasswordFile holds user=PASSWORD and owner=PASSWORD lines replacing
+	// UserPassword and OwnerPassword.
+	PasswordFile string
+	// PasswordCSV has the columns project, user_password and
+	// owner_password and overrides the passwords per project.
+	PasswordCSV string
+	// EncryptKeyLength is the AES key length, 128 or 256, defaults to 256.
+	EncryptKeyLength int
+	// Permissions is "none", "print" or "all", defaults to "print".
+	Permissions string
+	// InputPassword is tried on every encrypted source.
+	InputPassword string
+	// InputPasswordFile holds GLOB=PASSWORD lines, the password of the
+	// first glob matching the file name of an encrypted source is tried
+	// before InputPassword.
+	InputPasswordFile string
+	// OnEncrypted is "fail" to fail projects with a source none of the
+	// passwords decrypts or "skip" to leave such sources out, defaults to
+	// "fail".
+	OnEncrypted string
+	// OnInvalid is "fail" to fail projects with a source that doesn't pass
+	// validation or "skip" to leave such sources out, defaults to "fail".
+	// Either way the source goes to the QuarantineDir of OutputDir.
+	OnInvalid string
+	// QuarantineMode is "copy" to copy invalid sources to the quarantine
+	// or "move" to move them there, defaults to "copy".
+	QuarantineMode string
+	// InputValidation and OutputValidation are "strict", "relaxed" or
+	// "none" and default to "relaxed". Inputs pdfcpu can't read at all are
+	// invalid even with "none".
+	InputValidation  string
+	OutputValidation string
+	// ValidateLinks also checks that the URLs of link annotations can be
+	// reached.
+	ValidateLinks bool
+	// Force rebuilds every project, also those whose sources and options
+	// didn't change since the output was built.
+	Force bool
+}
+
+// Merger plans and executes merges for one set of Options. It keeps no
+// global state, so several can run in the same process.
+type Merger struct {
+	opts               Options
+	logger             zerolog.Logger
+	groupRegexp        *regexp.Regexp
+	signatureRules     []signatureRule
+	bookmarkTitle      *template.Template
+	bates              *batesCounter
+	properties         map[string]*template.Template
+	pageSize           *pageSize
+	pageSizeRules      []pageSizeRule
+	orientationRules   []orientationRule
+	runDate            time.Time
+	defaultPasswords   Passwords
+	projectPasswords   map[string]Passwords
+	inputPasswordRules []inputPasswordRule
+	invalid            *invalidInputs
+	stateKey           []byte
+}
+
+// New checks the options and returns a Merger logging to logger.
+func New(opts Options, logger zerolog.Logger) (*Merger, error) {
+	if opts.GroupPattern == "" {
+		opts.GroupPattern = DefaultGroupPattern
+	}
+	if opts.Symlinks == "" {
+		opts.Symlinks = "follow"
+	}
+	if opts.Order == "" {
+		opts.Order = "natural"
+	}
+	if opts.Jobs < 1 {
+		opts.Jobs = 1
+	}
+	if opts.SignatureDir == "" {
+		opts.SignatureDir = opts.OutputDir
+	}
+	if opts.BookmarkTitle == "" {
+		opts.BookmarkTitle = DefaultBookmarkTitle
+	}
+	if opts.BatesDigits < 1 {
+		opts.BatesDigits = 6
+	}
+	if opts.BatesStart < 1 {
+		opts.BatesStart = 1
+	}
+	if opts.BatesFont == "" {
+		opts.BatesFont = "Helvetica"
+	}
+	if opts.BatesFontSize < 1 {
+		opts.BatesFontSize = 9
+	}
+	if opts.BatesPosition == "" {
+		opts.BatesPosition = "br"
+	}
+	if opts.PageSizePolicy == "" {
+		opts.PageSizePolicy = "fit"
+	}
+	if opts.RotateDegrees == 0 {
+		opts.RotateDegrees = 90
+	}
+	if opts.EncryptKeyLength == 0 {
+		opts.EncryptKeyLength = 256
+	}
+	if opts.Permissions == "" {
+		opts.Permissions = "print"
+	}
+	if opts.OnEncrypted == "" {
+		opts.OnEncrypted = "fail"
+	}
+	if opts.OnInvalid == "" {
+		opts.OnInvalid = "fail"
+	}
+	if opts.QuarantineMode == "" {
+		opts.QuarantineMode = "copy"
+	}
+	if opts.InputValidation == "" {
+		opts.InputValidation = "relaxed"
+	}
+	if opts.OutputValidation == "" {
+		opts.OutputValidation = "relaxed"
+	}
+
+	if opts.OutputDir == "" {
+		return nil, errors.New("no output directory")
+	}
+	if opts.InputDir == "" && opts.Manifest == "" {
+		return nil, errors.New("no input directory or manifest")
+	}
+
+	m := &Merger{opts: opts, logger: logger, runDate: time.Now()}
+
+	reg, err := regexp.Compile(opts.GroupPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group pattern: %s", err.Error())
+	}
+	if reg.SubexpIndex("project") < 0 {
+		return nil, fmt.Errorf("group pattern %q is missing the named group \"project\"", opts.GroupPattern)
+	}
+	m.groupRegexp = reg
+
+	switch opts.Recursive {
+	case "", "flatten", "directory":
+	default:
+		return nil, fmt.Errorf("unknown recursive mode %q, must be \"flatten\" or \"directory\"", opts.Recursive)
+	}
+
+	switch opts.Symlinks {
+	case "follow", "skip", "error":
+	default:
+		return nil, fmt.Errorf("unknown symlink policy %q, must be \"follow\", \"skip\" or \"error\"", opts.Symlinks)
+	}
+
+	if _, ok := orderStrategies[opts.Order]; !ok {
+		return nil, fmt.Errorf("unknown order %q, must be one of %s", opts.Order, strings.Join(OrderNames(), ", "))
+	}
+
+	for _, rule := range opts.SignatureRules {
+		r, err := parseSignatureRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		m.signatureRules = append(m.signatureRules, r)
+	}
+
+	m.bookmarkTitle, err = template.New("bookmark").Option("missingkey=zero").Parse(opts.BookmarkTitle)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bookmark title: %s", err.Error())
+	}
+	if opts.BookmarkGroup != "" && reg.SubexpIndex(opts.BookmarkGroup) < 0 {
+		return nil, fmt.Errorf("group pattern %q is missing the bookmark group %q", opts.GroupPattern, opts.BookmarkGroup)
+	}
+
+	if m.properties, err = parseProperties(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.PageSize != "" {
+		if m.pageSize, err = parsePageSize(opts.PageSize, opts.PageSizePolicy); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range opts.PageSizeRules {
+		r, err := parsePageSizeRule(rule, opts.PageSizePolicy)
+		if err != nil {
+			return nil, err
+		}
+		m.pageSizeRules = append(m.pageSizeRules, r)
+	}
+
+	if err := checkOrientation(opts.Orientation); err != nil {
+		return nil, err
+	}
+	for _, rule := range opts.OrientationRules {
+		r, err := parseOrientationRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		m.orientationRules = append(m.orientationRules, r)
+	}
+	if opts.RotateDegrees != 90 && opts.RotateDegrees != -90 {
+		return nil, fmt.Errorf("invalid rotation %d, must be 90 or -90", opts.RotateDegrees)
+	}
+
+	if opts.BatesPrefix != "" {
+		valid := false
+		for _, pos := range batesPositions {
+			valid = valid || pos == opts.BatesPosition
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown Bates position %q, must be one of %s", opts.BatesPosition, strings.Join(batesPositions, ", "))
+		}
+		if _, err := m.batesWatermark(opts.BatesPrefix); err != nil {
+			return nil, fmt.Errorf("invalid Bates stamp: %s", err.Error())
+		}
+	}
+
+	if opts.Encrypt {
+		if opts.EncryptKeyLength != 128 && opts.EncryptKeyLength != 256 {
+			return nil, fmt.Errorf("invalid key length %d, must be 128 or 256", opts.EncryptKeyLength)
+		}
+		if _, ok := permissionPresets[opts.Permissions]; !ok {
+			return nil, fmt.Errorf("unknown permissions %q, must be \"none\", \"print\" or \"all\"", opts.Permissions)
+		}
+		if err := m.loadPasswords(); err != nil {
+			return nil, err
+		}
+	} else if opts.PasswordFile != "" || opts.PasswordCSV != "" {
+		// the outputs would silently be written in the clear
+		return nil, errors.New("--password-file and --password-csv need --encrypt")
+	}
+
+	if opts.InputPasswordFile != "" {
+		if m.inputPasswordRules, err = readInputPasswordFile(opts.InputPasswordFile); err != nil {
+			return nil, err
+		}
+	}
+	if opts.OnEncrypted != "fail" && opts.OnEncrypted != "skip" {
+		return nil, fmt.Errorf("unknown policy %q for encrypted inputs, must be \"fail\" or \"skip\"", opts.OnEncrypted)
+	}
+	if opts.OnInvalid != "fail" && opts.OnInvalid != "skip" {
+		return nil, fmt.Errorf("unknown policy %q for invalid inputs, must be \"fail\" or \"skip\"", opts.OnInvalid)
+	}
+	if opts.QuarantineMode != "copy" && opts.QuarantineMode != "move" {
+		return nil, fmt.Errorf("unknown quarantine mode %q, must be \"copy\" or \"move\"", opts.QuarantineMode)
+	}
+	if err := checkValidationMode("input", opts.InputValidation); err != nil {
+		return nil, err
+	}
+	if err := checkValidationMode("output", opts.OutputValidation); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// WithLogger returns a copy of m logging to logger.
+func (m *Merger) WithLogger(logger zerolog.Logger) *Merger {
+	c := *m
+	c.logger = logger
+	return &c
+}
+
+// Options returns the options of m with defaults filled in.
+func (m *Merger) Options() Options {
+	return m.opts
+}
+
+// Execute merges the projects of plan, up to Options.Jobs at a time, and
+// returns a result per project in plan order. A failing project doesn't stop
+// the others unless Options.FailFast is set, projects that were never
+// started because of that or because ctx was cancelled are skipped.
+func (m *Merger) Execute(ctx context.Context, plan *Plan) ([]Result, error) {
+	// pdfcpu loads its default configuration lazily and without locking,
+	// make sure that happens before any worker starts
+	model.NewDefaultConfiguration()
+
+	state, err := loadBuildState(m.opts.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := *m
+	c.invalid = newInvalidInputs()
+	if m.usesPasswords() {
+		// without it projects with passwords are always rebuilt
+		if c.stateKey, err = loadStateKey(); err != nil {
+			m.logger.Warn().Msgf("unable to read the key passwords are hashed with: %s", err.Error())
+		}
+	}
+	if m.opts.BatesPrefix != "" {
+		bates, err := m.loadBates(len(plan.Projects))
+		if err != nil {
+			return nil, err
+		}
+		c.bates = bates
+	}
+	m = &c
+
+	type finishedProject struct {
+		index  int
+		result Result
+		log    *bytes.Buffer
+	}
+
+	indexes := make(chan int)
+	finished := make(chan finishedProject)
+
+	// stop is closed by the first failing project in fail-fast mode
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	var wg sync.WaitGroup
+	for w := 0; w < m.opts.Jobs && w < len(plan.Projects); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				p := plan.Projects[i]
+				if reason := stopReason(ctx, stop); reason != "" {
+					m.releaseBates(i)
+					finished <- finishedProject{index: i, result: skippedResult(p, reason)}
+					continue
+				}
+
+				pm, log := m.projectMerger()
+				hash, err := pm.planHash(p)
+				if err != nil {
+					// the merge will fail on the same source, or the project
+					// is rebuilt without a key for its passwords
+					hash = ""
+				}
+				if hash != "" && !m.opts.Force && state.upToDate(p.Output, hash) {
+					m.releaseBates(i)
+					result := Result{Project: p.Name, Output: p.Output, Status: StatusUnchanged, Reason: "unchanged since the last build", SkippedSources: p.Skipped}
+					finished <- finishedProject{index: i, result: result, log: log}
+					continue
+				}
+
+				result, err := pm.mergePDF(ctx, i, p)
+				m.releaseBates(i)
+				result.Project, result.Output, result.Status = p.Name, p.Output, StatusSuccess
+				result.planHash = hash
+				// files left out when planning come first
+				result.SkippedSources = append(append([]SkippedFile{}, p.Skipped...), result.SkippedSources...)
+				if err != nil {
+					pm.logger.Warn().Msgf("error merging PDFs: %s", err.Error())
+					result.Status = StatusFailed
+					result.Err = err
+					if m.opts.FailFast {
+						stopOnce.Do(func() { close(stop) })
+					}
+				}
+				finished <- finishedProject{index: i, result: result, log: log}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(indexes)
+		for i := range plan.Projects {
+			if stopReason(ctx, stop) != "" {
+				return
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	// flush the logs of finished projects in plan order so the lines of a
+	// project stay together and the output doesn't depend on timing
+	done := make([]*finishedProject, len(plan.Projects))
+	flushed := 0
+	for f := range finished {
+		f := f
+		done[f.index] = &f
+		for flushed < len(done) && done[flushed] != nil {
+			if done[flushed].log != nil {
+				m.replayLog(done[flushed].log)
+			}
+			flushed++
+		}
+	}
+
+	results := []Result{}
+	for i, f := range done {
+		result := skippedResult(plan.Projects[i], stopReason(ctx, stop))
+		if f != nil {
+			result = f.result
+		}
+		if result.Status == StatusSkipped || result.Status == StatusUnchanged {
+			m.logger.Info().Msgf("skipping project %s: %s", result.Project, result.Reason)
+		}
+		if result.Status == StatusSuccess && result.planHash != "" {
+			if built, err := builtFrom(plan.Projects[i], result.planHash); err == nil {
+				state[result.Output] = built
+			}
+		}
+		results = append(results, result)
+	}
+
+	// failing to save it only costs a rebuild next time
+	if err := state.save(m.opts.OutputDir); err != nil {
+		m.logger.Warn().Msgf("unable to save build state: %s", err.Error())
+	}
+
+	if m.bates != nil {
+		rows := [][]string{}
+		for _, result := range results {
+			if result.bates != nil {
+				rows = append(rows, result.bates.rows...)
+			}
+		}
+		if err := m.bates.save(rows); err != nil {
+			return results, err
+		}
+	}
+
+	return results, ctx.Err()
+}
+
+// why remaining projects are skipped, empty if they aren't
+func stopReason(ctx context.Context, stop chan struct{}) string {
+	if ctx.Err() != nil {
+		return "cancelled"
+	}
+	select {
+	case <-stop:
+		return "fail-fast after an earlier failure"
+	default:
+		return ""
+	}
+}
+
+// releaseBates lets the project after index take its Bates numbers once
+// index is done with the counter
+func (m *Merger) releaseBates(index int) {
+	if m.bates != nil {
+		m.bates.release(index)
+	}
+}
+
+// projectMerger returns the Merger a single project is merged with. With
+// more than one job its log lines are buffered until the project is done.
+func (m *Merger) projectMerger() (*Merger, *bytes.Buffer) {
+	if m.opts.Jobs == 1 {
+		return m, nil
+	}
+	buf := &bytes.Buffer{}
+	return m.WithLogger(zerolog.New(buf).Level(m.logger.GetLevel())), buf
+}
+
+// replayLog writes buffered JSON log lines to the logger of m
+func (m *Merger) replayLog(buf *bytes.Buffer) {
+	dec := json.NewDecoder(buf)
+	for {
+		var line struct {
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			return
+		}
+		level, err := zerolog.ParseLevel(line.Level)
+		if err != nil {
+			level = zerolog.NoLevel
+		}
+		m.logger.WithLevel(level).Msg(line.Message)
+	}
+}
@@ -0,0 +1,190 @@
+package merger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// orderStrategies sort the files of a single project in place, keyed by the
+// name accepted by Options.Order
+var orderStrategies = map[string]func(m *Merger, files []string) error{
+	"natural": (*Merger).orderNatural,
+	"seq":     (*Merger).orderBySequence,
+	"mtime":   (*Merger).orderByModTime,
+	"created": (*Merger).orderByCreationDate,
+}
+
+// OrderNames returns the names of every ordering strategy, sorted.
+func OrderNames() []string {
+	names := []string{}
+	for name := range orderStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Merger) orderFiles(files []string) error {
+	return orderStrategies[m.opts.Order](m, files)
+}
+
+// sort by file name only, comparing runs of digits by their numeric value so
+// that T_01-2.pdf comes before T_01-10.pdf
+func (m *Merger) orderNatural(files []string) error {
+	sort.SliceStable(files, func(i, j int) bool {
+		return fileNameLess(files[i], files[j])
+	})
+	return nil
+}
+
+// sort by the "seq" group of the group pattern, files without one go last
+func (m *Merger) orderBySequence(files []string) error {
+	sort.SliceStable(files, func(i, j int) bool {
+		seqI := m.parseSequence(filepath.Base(files[i]))
+		seqJ := m.parseSequence(filepath.Base(files[j]))
+		if seqI == seqJ {
+			return fileNameLess(files[i], files[j])
+		}
+		if seqI == "" || seqJ == "" {
+			return seqJ == ""
+		}
+		return naturalLess(seqI, seqJ)
+	})
+	return nil
+}
+
+// sort by file modification time, oldest first
+func (m *Merger) orderByModTime(files []string) error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	sortByTime(files, modTimes)
+	return nil
+}
+
+// sort by the CreationDate in the PDF info dictionary, oldest first, files
+// without a readable date go last
+func (m *Merger) orderByCreationDate(files []string) error {
+	created := make(map[string]time.Time)
+	for _, file := range files {
+		date, err := creationDate(file)
+		if err != nil {
+			m.logger.Warn().Msgf("unable to read creation date of %s: %s", file, err.Error())
+			continue
+		}
+		created[file] = date
+	}
+	sortByTime(files, created)
+	return nil
+}
+
+func sortByTime(files []string, times map[string]time.Time) {
+	sort.SliceStable(files, func(i, j int) bool {
+		timeI, okI := times[files[i]]
+		timeJ, okJ := times[files[j]]
+		if !okI || !okJ {
+			if okI == okJ {
+				return fileNameLess(files[i], files[j])
+			}
+			return okI
+		}
+		if timeI.Equal(timeJ) {
+			return fileNameLess(files[i], files[j])
+		}
+		return timeI.Before(timeJ)
+	})
+}
+
+func creationDate(file string) (time.Time, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	lines, err := api.Info(f, nil, conf)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range lines {
+		key, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(key) != "Creation date" {
+			continue
+		}
+		date, ok := types.DateTime(strings.TrimSpace(value), true)
+		if !ok {
+			return time.Time{}, fmt.Errorf("invalid creation date %q", strings.TrimSpace(value))
+		}
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("no creation date")
+}
+
+// fileNameLess compares file names without their extension first, so that
+// T_01-2.pdf goes before T_01-2 (1).pdf, then the full names and paths
+func fileNameLess(a, b string) bool {
+	baseA, baseB := filepath.Base(a), filepath.Base(b)
+	nameA, nameB := strings.TrimSuffix(baseA, filepath.Ext(baseA)), strings.TrimSuffix(baseB, filepath.Ext(baseB))
+	if nameA != nameB {
+		return naturalLess(nameA, nameB)
+	}
+	if baseA != baseB {
+		return naturalLess(baseA, baseB)
+	}
+	return naturalLess(a, b)
+}
+
+// naturalLess compares strings rune by rune, except that runs of digits are
+// compared by their numeric value
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		digitsA, digitsB := leadingDigits(a), leadingDigits(b)
+		if digitsA != "" && digitsB != "" {
+			numA, numB := strings.TrimLeft(digitsA, "0"), strings.TrimLeft(digitsB, "0")
+			if len(numA) != len(numB) {
+				return len(numA) < len(numB)
+			}
+			if numA != numB {
+				return numA < numB
+			}
+			if len(digitsA) != len(digitsB) {
+				return len(digitsA) < len(digitsB)
+			}
+			a, b = a[len(digitsA):], b[len(digitsB):]
+			continue
+		}
+
+		runeA, sizeA := utf8.DecodeRuneInString(a)
+		runeB, sizeB := utf8.DecodeRuneInString(b)
+		if runeA != runeB {
+			return runeA < runeB
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,109 @@
+package merger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestMerger returns a Merger for opts, with temporary input and output
+// directories unless they are set
+func newTestMerger(t *testing.T, opts Options) *Merger {
+	t.Helper()
+	if opts.InputDir == "" && opts.Manifest == "" {
+		opts.InputDir = t.TempDir()
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = t.TempDir()
+	}
+	m, err := New(opts, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	return m
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"a", "b", true},
+		{"b", "a", false},
+		{"a", "a", false},
+		{"a", "ab", true},
+		{"2", "10", true},
+		{"10", "2", false},
+		{"T_01-2.pdf", "T_01-10.pdf", true},
+		{"T_01-10.pdf", "T_01-2.pdf", false},
+		// equal numbers with fewer leading zeros go first
+		{"1", "01", true},
+		{"01", "1", false},
+		{"01", "2", true},
+		{"x9y", "x10a", true},
+		{"page 3", "page 3b", true},
+		{"ä1", "ä2", true},
+	}
+	for _, test := range tests {
+		if less := naturalLess(test.a, test.b); less != test.less {
+			t.Errorf("naturalLess(%q, %q) = %t, want %t", test.a, test.b, less, test.less)
+		}
+	}
+}
+
+func TestOrderNatural(t *testing.T) {
+	m := newTestMerger(t, Options{})
+	// a numbered copy goes after its original
+	files := []string{"in/T_01-10.pdf", "in/T_01-2 (1).pdf", "in/T_01-2.pdf", "in/T_01-1.pdf", "a/T_01-3.pdf", "b/T_01-3.pdf"}
+	if err := m.orderNatural(files); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"in/T_01-1.pdf", "in/T_01-2.pdf", "in/T_01-2 (1).pdf", "a/T_01-3.pdf", "b/T_01-3.pdf", "in/T_01-10.pdf"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v, want %v", files, want)
+	}
+}
+
+func TestOrderBySequence(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		files   []string
+		want    []string
+	}{
+		{
+			name:  "numeric suffixes",
+			files: []string{"T-10.pdf", "T-9.pdf", "T-1.pdf"},
+			want:  []string{"T-1.pdf", "T-9.pdf", "T-10.pdf"},
+		},
+		{
+			name:  "the suffix wins over the rest of the name",
+			files: []string{"T-2-a.pdf", "T-1-z.pdf"},
+			want:  []string{"T-1-z.pdf", "T-2-a.pdf"},
+		},
+		{
+			name:  "equal suffixes by file name",
+			files: []string{"T-1-b.pdf", "T-1-a.pdf"},
+			want:  []string{"T-1-a.pdf", "T-1-b.pdf"},
+		},
+		{
+			name:    "files without a suffix go last",
+			pattern: `^(?P<project>[^-]+)(-(?P<seq>[^-]+))?`,
+			files:   []string{"T.pdf", "T-2.pdf", "T-1.pdf"},
+			want:    []string{"T-1.pdf", "T-2.pdf", "T.pdf"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestMerger(t, Options{GroupPattern: test.pattern})
+			files := append([]string{}, test.files...)
+			if err := m.orderBySequence(files); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, test.want) {
+				t.Errorf("got %v, want %v", files, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// orientationRule overrides the orientation for files matching a glob
+type orientationRule struct {
+	glob        string
+	orientation string
+}
+
+func checkOrientation(orientation string) error {
+	switch orientation {
+	case "", "none", "portrait", "landscape":
+		return nil
+	}
+	return fmt.Errorf("unknown orientation %q, must be \"portrait\", \"landscape\" or \"none\"", orientation)
+}
+
+// parseOrientationRule parses GLOB=ORIENTATION
+func parseOrientationRule(rule string) (orientationRule, error) {
+	glob, orientation, err := parseGlobRule(rule, "GLOB=ORIENTATION")
+	if err != nil {
+		return orientationRule{}, fmt.Errorf("invalid orientation rule %q: %s", rule, err.Error())
+	}
+	if err := checkOrientation(orientation); err != nil {
+		return orientationRule{}, fmt.Errorf("invalid orientation rule %q: %s", rule, err.Error())
+	}
+	return orientationRule{glob: glob, orientation: orientation}, nil
+}
+
+// the orientation of the first rule matching the file name of source, or
+// the default orientation
+func (m *Merger) sourceOrientation(source Source) string {
+	for _, r := range m.orientationRules {
+		if matchGlob(r.glob, source) {
+			return r.orientation
+		}
+	}
+	return m.opts.Orientation
+}
+
+// rotatePages turns every page of r that isn't in orientation by
+// Options.RotateDegrees. Square pages are left alone.
+func (m *Merger) rotatePages(source Source, r io.ReadSeeker, orientation string) (io.ReadSeeker, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	ctx, err := api.ReadContext(r, conf)
+	if err != nil {
+		return nil, err
+	}
+	// the dimensions already account for /Rotate
+	dims, err := ctx.PageDims()
+	if err != nil {
+		return nil, err
+	}
+	boundaries, err := ctx.PageBoundaries()
+	if err != nil {
+		return nil, err
+	}
+
+	pages := []string{}
+	rotated := []string{}
+	for i, dim := range dims {
+		landscape := dim.Width > dim.Height
+		portrait := dim.Height > dim.Width
+		if (orientation == "portrait" && landscape) || (orientation == "landscape" && portrait) {
+			pages = append(pages, strconv.Itoa(i+1))
+			rotated = append(rotated, fmt.Sprintf("rotated page %d of %s by %d degrees to %s, it was %.0fx%.0f with /Rotate %d",
+				i+1, source.Path, m.opts.RotateDegrees, orientation, dim.Width, dim.Height, boundaries[i].Rot))
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return r, nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := api.Rotate(r, buf, m.opts.RotateDegrees, pages, conf); err != nil {
+		return nil, err
+	}
+	for _, msg := range rotated {
+		m.logger.Info().Msg(msg)
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
@@ -0,0 +1,36 @@
+package merger
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writeTemp writes b to a new temporary file next to output and syncs it to
+// disk. The caller renames it to output or removes it.
+func writeTemp(output string, b []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	// os.CreateTemp creates the file only readable by its owner
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,221 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// pages within this many points of the page size are left alone
+const pageSizeTolerance = 1
+
+// pageSize is a page size and the policy to bring pages to it
+type pageSize struct {
+	name   string
+	dim    types.Dim
+	policy string
+}
+
+// pageSizeRule overrides the page size for files matching a glob
+type pageSizeRule struct {
+	glob string
+	// size is nil to leave matching files alone
+	size *pageSize
+}
+
+// ScaledPages are consecutive pages of a source that had the same size and
+// were scaled by the same factor to the page size.
+type ScaledPages struct {
+	Path string
+	// FirstPage and LastPage count the pages of the source, after any page
+	// selection.
+	FirstPage int
+	LastPage  int
+	// Width and Height are the original size in points.
+	Width  float64
+	Height float64
+	Scale  float64
+	// Size is the page size and Policy how it was applied.
+	Size   string
+	Policy string
+}
+
+// parsePageSize parses SIZE[:POLICY], where SIZE is a paper size such as A4
+// or Letter or WIDTHxHEIGHT in points
+func parsePageSize(s, policy string) (*pageSize, error) {
+	name, p, found := strings.Cut(s, ":")
+	if found {
+		policy = p
+	}
+	switch policy {
+	case "fit", "fill", "center":
+	default:
+		return nil, fmt.Errorf("unknown page size policy %q, must be \"fit\", \"fill\" or \"center\"", policy)
+	}
+
+	size := &pageSize{name: name, policy: policy}
+	if w, h, found := strings.Cut(name, "x"); found {
+		width, errW := strconv.ParseFloat(w, 64)
+		height, errH := strconv.ParseFloat(h, 64)
+		if errW != nil || errH != nil || width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("invalid page size %q, must be WIDTHxHEIGHT in points", name)
+		}
+		size.dim = types.Dim{Width: width, Height: height}
+		return size, nil
+	}
+	for paper, dim := range types.PaperSize {
+		if strings.EqualFold(paper, name) {
+			size.dim = *dim
+			return size, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown page size %q, must be a paper size such as A4 or Letter or WIDTHxHEIGHT in points", name)
+}
+
+// parsePageSizeRule parses GLOB=SIZE[:POLICY] or GLOB=none
+func parsePageSizeRule(rule, policy string) (pageSizeRule, error) {
+	glob, size, err := parseGlobRule(rule, "GLOB=SIZE[:POLICY] or GLOB=none")
+	if err != nil {
+		return pageSizeRule{}, fmt.Errorf("invalid page size rule %q: %s", rule, err.Error())
+	}
+	r := pageSizeRule{glob: glob}
+	if size == "none" {
+		return r, nil
+	}
+	if r.size, err = parsePageSize(size, policy); err != nil {
+		return pageSizeRule{}, fmt.Errorf("invalid page size rule %q: %s", rule, err.Error())
+	}
+	return r, nil
+}
+
+// the page size of the first rule matching the file name of source, or the
+// default page size
+func (m *Merger) sourcePageSize(source Source) *pageSize {
+	for _, r := range m.pageSizeRules {
+		if matchGlob(r.glob, source) {
+			return r.size
+		}
+	}
+	return m.pageSize
+}
+
+// resizePages brings every page of r to size. Pages are scaled with
+// api.Resize, by the smaller factor for "fit" and the larger for "fill" and
+// not at all for "center", then their media box is set to size centered on
+// the content, padding or cropping it.
+func resizePages(source Source, r io.ReadSeeker, size *pageSize) (io.ReadSeeker, []ScaledPages, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	dims, err := api.PageDims(r, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// page size turned like every page and the factor it is scaled by
+	targets := make(map[int]types.Dim)
+	scales := make(map[float64][]string)
+	scaled := []ScaledPages{}
+	for i, dim := range dims {
+		page := i + 1
+		target := size.dim
+		if (dim.Width > dim.Height) != (target.Width > target.Height) {
+			target.Width, target.Height = target.Height, target.Width
+		}
+		if math.Abs(dim.Width-target.Width) < pageSizeTolerance && math.Abs(dim.Height-target.Height) < pageSizeTolerance {
+			continue
+		}
+		targets[page] = target
+
+		scale := 1.0
+		switch size.policy {
+		case "fit":
+			scale = math.Min(target.Width/dim.Width, target.Height/dim.Height)
+		case "fill":
+			scale = math.Max(target.Width/dim.Width, target.Height/dim.Height)
+		}
+		scale = math.Round(scale*10000) / 10000
+		if scale != 1 {
+			scales[scale] = append(scales[scale], strconv.Itoa(page))
+		}
+
+		if n := len(scaled); n > 0 && scaled[n-1].LastPage == page-1 && scaled[n-1].Scale == scale &&
+			scaled[n-1].Width == dim.Width && scaled[n-1].Height == dim.Height {
+			scaled[n-1].LastPage = page
+			continue
+		}
+		scaled = append(scaled, ScaledPages{
+			Path:      source.Path,
+			FirstPage: page,
+			LastPage:  page,
+			Width:     dim.Width,
+			Height:    dim.Height,
+			Scale:     scale,
+			Size:      size.name,
+			Policy:    size.policy,
+		})
+	}
+	if len(targets) == 0 {
+		_, err := r.Seek(0, io.SeekStart)
+		return r, nil, err
+	}
+
+	// a pass per factor, in a fixed order so outputs are reproducible
+	factors := []float64{}
+	for scale := range scales {
+		factors = append(factors, scale)
+	}
+	sort.Float64s(factors)
+	for _, scale := range factors {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		buf := &bytes.Buffer{}
+		res := &model.Resize{Scale: scale, Unit: types.POINTS}
+		if err := api.Resize(r, buf, scales[scale], res, conf); err != nil {
+			return nil, nil, err
+		}
+		r = bytes.NewReader(buf.Bytes())
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	ctx, err := api.ReadContext(r, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	for page, target := range targets {
+		d, _, inh, err := ctx.PageDict(page, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		content := inh.MediaBox
+		if inh.CropBox != nil {
+			content = inh.CropBox
+		}
+		// the media box is in unrotated space, api.Resize drops /Rotate
+		// but unscaled pages keep theirs
+		if inh.Rotate%180 != 0 {
+			target.Width, target.Height = target.Height, target.Width
+		}
+		llx := content.LL.X + (content.Width()-target.Width)/2
+		lly := content.LL.Y + (content.Height()-target.Height)/2
+		box := types.NewRectangle(llx, lly, llx+target.Width, lly+target.Height)
+		d.Update("MediaBox", box.Array())
+		d.Update("CropBox", box.Array())
+	}
+
+	buf := &bytes.Buffer{}
+	if err := api.WriteContext(ctx, buf); err != nil {
+		return nil, nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), scaled, nil
+}
@@ -0,0 +1,100 @@
+package merger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Plan is everything a run is going to do, built without writing any files
+// so it can be printed or handed to Execute.
+type Plan struct {
+	Projects []ProjectPlan `json:"projects"`
+	Skipped  []SkippedFile `json:"skipped,omitempty"`
+}
+
+// ProjectPlan is the output and ordered sources of a single project.
+type ProjectPlan struct {
+	Name       string        `json:"name"`
+	Output     string        `json:"output"`
+	Order      string        `json:"order"`
+	Sources    []Source      `json:"sources"`
+	Signatures []string      `json:"signatures,omitempty"`
+	Skipped    []SkippedFile `json:"skipped,omitempty"`
+}
+
+// Source is a single input of a merge in merge order, optionally limited to
+// a page selection such as "1-3,5". Signature files carry the suffix of
+// their signature set.
+type Source struct {
+	Path      string   `json:"path"`
+	Pages     []string `json:"pages,omitempty"`
+	Signature bool     `json:"signature,omitempty"`
+	Set       string   `json:"set,omitempty"`
+}
+
+// SkippedFile is an input that was left out of the plan, with the reason.
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// Plan scans the input directory, or reads the manifest, and works out the
+// ordered sources of every project including inserted signature files.
+func (m *Merger) Plan(ctx context.Context) (*Plan, error) {
+	if m.opts.Manifest != "" {
+		return m.planFromManifest()
+	}
+
+	signatureFiles, err := m.parseSignatureFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scan{projects: make(map[string][]string), hashes: make(map[string]string)}
+	if err := m.walkDir(ctx, s, m.opts.InputDir, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Skipped: s.skipped}
+	for _, pName := range sortProjects(s.projects) {
+		p, err := m.planProject(pName, s.projects[pName], signatureFiles, s.hashes)
+		if err != nil {
+			return nil, err
+		}
+		plan.Projects = append(plan.Projects, p)
+	}
+	m.warnSharedFiles(plan.Projects, s.hashes)
+	return plan, nil
+}
+
+func (m *Merger) planProject(project string, projectFiles []string, signatureFiles map[string][]string, hashes map[string]string) (ProjectPlan, error) {
+	p := ProjectPlan{
+		Name:   project,
+		Output: filepath.Join(m.opts.OutputDir, project+".pdf"),
+		Order:  m.opts.Order,
+	}
+
+	files := append([]string{}, projectFiles...)
+	if err := m.orderFiles(files); err != nil {
+		return p, fmt.Errorf("unable to order files of project %s: %s", project, err.Error())
+	}
+	files, p.Skipped = m.dropDuplicates(project, files, hashes)
+
+	p.Sources = m.addSigFiles(project, files, signatureFiles)
+	for _, source := range p.Sources {
+		if source.Signature {
+			p.Signatures = append(p.Signatures, source.Path)
+		}
+	}
+	return p, nil
+}
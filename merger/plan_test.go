@@ -0,0 +1,95 @@
+package merger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates every file below dir with its name as contents, so no
+// two files are identical
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	in, sig := t.TempDir(), t.TempDir()
+	writeFiles(t, in, "B-1.pdf", "A-10.pdf", "A-2.pdf", "notes.txt", "stray.pdf", "sub/A-3.pdf")
+	writeFiles(t, sig, "signature-2.pdf")
+
+	m := newTestMerger(t, Options{InputDir: in, SignatureDir: sig, GroupPattern: `^(?P<project>[A-Z])-(?P<seq>\d+)`})
+	plan, err := m.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ProjectPlan{
+		{
+			Name:   "A",
+			Output: filepath.Join(m.opts.OutputDir, "A.pdf"),
+			Order:  "natural",
+			Sources: []Source{
+				{Path: filepath.Join(in, "A-2.pdf")},
+				{Path: filepath.Join(sig, "signature-2.pdf"), Signature: true, Set: "2"},
+				{Path: filepath.Join(in, "A-10.pdf")},
+			},
+			Signatures: []string{filepath.Join(sig, "signature-2.pdf")},
+			Skipped:    []SkippedFile{},
+		},
+		{
+			Name:    "B",
+			Output:  filepath.Join(m.opts.OutputDir, "B.pdf"),
+			Order:   "natural",
+			Sources: []Source{{Path: filepath.Join(in, "B-1.pdf")}},
+			Skipped: []SkippedFile{},
+		},
+	}
+	if !reflect.DeepEqual(plan.Projects, want) {
+		t.Errorf("got projects %+v, want %+v", plan.Projects, want)
+	}
+
+	skipped := []SkippedFile{
+		{Path: filepath.Join(in, "notes.txt"), Reason: "not a pdf file"},
+		{Path: filepath.Join(in, "stray.pdf"), Reason: "does not match group pattern"},
+		{Path: filepath.Join(in, "sub"), Reason: "directory"},
+	}
+	if !reflect.DeepEqual(plan.Skipped, skipped) {
+		t.Errorf("got skipped %+v, want %+v", plan.Skipped, skipped)
+	}
+}
+
+func TestPlanRecursiveDirectory(t *testing.T) {
+	in := t.TempDir()
+	writeFiles(t, in, "x/one-2.pdf", "x/one-1.pdf", "y/z/two-1.pdf")
+
+	m := newTestMerger(t, Options{InputDir: in, Recursive: "directory"})
+	plan, err := m.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string][]string)
+	for _, p := range plan.Projects {
+		for _, s := range p.Sources {
+			got[p.Name] = append(got[p.Name], filepath.Base(s.Path))
+		}
+	}
+	want := map[string][]string{
+		"x":   {"one-1.pdf", "one-2.pdf"},
+		"y/z": {"two-1.pdf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,222 @@
+package merger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// QuarantineDir is the directory in the output directory invalid inputs are
+// moved or copied to.
+const QuarantineDir = "quarantine"
+
+// invalidInputs remembers the inputs found invalid during a run, so inputs
+// shared by several projects, such as signatures, are quarantined once
+type invalidInputs struct {
+	mu      sync.Mutex
+	reasons map[string]string
+}
+
+func newInvalidInputs() *invalidInputs {
+	return &invalidInputs{reasons: make(map[string]string)}
+}
+
+func (v *invalidInputs) reason(file string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	reason, ok := v.reasons[file]
+	return reason, ok
+}
+
+// quarantine moves or copies the invalid source with the contents b to the
+// quarantine directory, next to a .reason.txt file with reason, unless that
+// already happened in this run
+func (m *Merger) quarantine(source Source, b []byte, reason string) error {
+	m.invalid.mu.Lock()
+	defer m.invalid.mu.Unlock()
+	if _, ok := m.invalid.reasons[source.Path]; ok {
+		return nil
+	}
+	m.invalid.reasons[source.Path] = reason
+
+	dir := filepath.Join(m.opts.OutputDir, QuarantineDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// never overwrite an earlier file of the same name, but don't copy the
+	// same file again on every run either
+	ext := filepath.Ext(source.Path)
+	name := strings.TrimSuffix(filepath.Base(source.Path), ext)
+	target := filepath.Join(dir, name+ext)
+	for n := 2; ; n++ {
+		info, err := os.Lstat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err == nil && info.Mode().IsRegular() && info.Size() == int64(len(b)) {
+			if earlier, err := os.ReadFile(target); err == nil && bytes.Equal(earlier, b) {
+				return m.alreadyQuarantined(source, target)
+			}
+		}
+		target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, n, ext))
+	}
+
+	if err := os.WriteFile(target+".reason.txt", []byte(fmt.Sprintf("%s\n%s\n", source.Path, reason)), 0644); err != nil {
+		return err
+	}
+	if m.opts.QuarantineMode == "move" {
+		if err := os.Rename(source.Path, target); err == nil {
+			m.logger.Warn().Msgf("moved invalid %s to %s", source.Path, target)
+			return nil
+		}
+	}
+	if err := os.WriteFile(target, b, 0644); err != nil {
+		return err
+	}
+	if m.opts.QuarantineMode == "move" {
+		// the rename failed, for example across file systems
+		if err := os.Remove(source.Path); err != nil {
+			return err
+		}
+		m.logger.Warn().Msgf("moved invalid %s to %s", source.Path, target)
+		return nil
+	}
+	m.logger.Warn().Msgf("copied invalid %s to %s", source.Path, target)
+	return nil
+}
+
+// alreadyQuarantined handles an invalid source identical to target, which an
+// earlier run put in the quarantine directory
+func (m *Merger) alreadyQuarantined(source Source, target string) error {
+	if m.opts.QuarantineMode == "move" {
+		if err := os.Remove(source.Path); err != nil {
+			return err
+		}
+		m.logger.Warn().Msgf("removed invalid %s, it is already quarantined as %s", source.Path, target)
+		return nil
+	}
+	m.logger.Warn().Msgf("invalid %s is already quarantined as %s", source.Path, target)
+	return nil
+}
+
+// prepareSources reads every source of p once, decrypts the encrypted ones
+// and validates them. Sources that can't be decrypted or are invalid fail
+// the project, or are left out of it with Options.OnEncrypted and
+// Options.OnInvalid "skip", and invalid ones are quarantined either way.
+// Signature sets placed before or after a document that was left out are
+// left out with it. It returns the sources to merge and the decrypted
+// contents by path, and adds the sources left out and the validations to
+// result.
+func (m *Merger) prepareSources(p ProjectPlan, result *Result) ([]Source, map[string][]byte, error) {
+	decrypted := make(map[string][]byte)
+	left := make(map[string]bool)
+	prepare := func(source Source) error {
+		if _, ok := decrypted[source.Path]; ok || left[source.Path] {
+			return nil
+		}
+		b, policy, err := m.prepareSource(source, result)
+		if err != nil && policy == "skip" {
+			m.logger.Warn().Msgf("skipping %s: %s", source.Path, err.Error())
+			result.SkippedSources = append(result.SkippedSources, SkippedFile{Path: source.Path, Reason: err.Error()})
+			left[source.Path] = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to merge %s: %s", source.Path, err.Error())
+		}
+		decrypted[source.Path] = b
+		return nil
+	}
+
+	// the documents go first, to know which signature sets to leave out
+	for _, source := range p.Sources {
+		if source.Signature {
+			continue
+		}
+		if err := prepare(source); err != nil {
+			return nil, nil, err
+		}
+	}
+	anchors := m.signatureAnchors(p)
+	sources := []Source{}
+	documents := 0
+	for i, source := range p.Sources {
+		if anchor, ok := anchors[i]; ok && left[anchor] {
+			m.logger.Warn().Msgf("leaving out %s, placed next to the skipped %s", source.Path, anchor)
+			continue
+		}
+		if source.Signature {
+			if err := prepare(source); err != nil {
+				return nil, nil, err
+			}
+		}
+		if left[source.Path] {
+			continue
+		}
+		if !source.Signature {
+			documents++
+		}
+		sources = append(sources, source)
+	}
+	for file, b := range decrypted {
+		if b == nil {
+			delete(decrypted, file)
+		}
+	}
+	// signature sets alone are no output
+	if documents == 0 {
+		return nil, nil, errors.New("no documents left to merge")
+	}
+	return sources, decrypted, nil
+}
+
+// prepareSource returns the decrypted contents of source, or nil if it isn't
+// encrypted, or why it can't be merged and the policy for that
+func (m *Merger) prepareSource(source Source, result *Result) ([]byte, string, error) {
+	invalid := func(reason string) ([]byte, string, error) {
+		result.Validations = append(result.Validations, Validation{Path: source.Path, Mode: m.opts.InputValidation, Err: reason})
+		return nil, m.opts.OnInvalid, fmt.Errorf("invalid: %s", reason)
+	}
+
+	if reason, ok := m.invalid.reason(source.Path); ok {
+		return invalid(reason)
+	}
+	b, err := os.ReadFile(source.Path)
+	if err != nil {
+		// another project may just have moved it to the quarantine
+		if reason, ok := m.invalid.reason(source.Path); ok {
+			return invalid(reason)
+		}
+		return nil, "fail", err
+	}
+
+	decrypted, err := m.decrypt(source, b)
+	if errors.Is(err, errNoPassword) {
+		return nil, m.opts.OnEncrypted, err
+	}
+	// files pdfcpu can't read at all are invalid in every mode
+	if err == nil && m.opts.InputValidation != "none" {
+		conf := m.validationConf(m.opts.InputValidation)
+		if decrypted != nil {
+			err = api.Validate(bytes.NewReader(decrypted), conf)
+		} else {
+			err = api.Validate(bytes.NewReader(b), conf)
+		}
+	}
+	if err != nil {
+		// some pdfcpu errors end in a newline
+		reason := strings.TrimSpace(err.Error())
+		if err := m.quarantine(source, b, reason); err != nil {
+			return nil, "fail", fmt.Errorf("unable to quarantine: %s", err.Error())
+		}
+		return invalid(reason)
+	}
+	result.Validations = append(result.Validations, Validation{Path: source.Path, Mode: m.opts.InputValidation})
+	return decrypted, "", nil
+}
@@ -0,0 +1,121 @@
+package merger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// writePDF writes a valid one page PDF to file
+func writePDF(t *testing.T, file string) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := api.Create(nil, strings.NewReader(`{"pages": {"1": {"content": {"text": [{"value": "page", "pos": [100, 100], "font": {"name": "Helvetica", "size": 12}}]}}}}`), f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrepareSources(t *testing.T) {
+	in, sig := t.TempDir(), t.TempDir()
+	// T-1.pdf is invalid and skipped
+	writeFiles(t, in, "T-1.pdf")
+	writePDF(t, filepath.Join(in, "T-2.pdf"))
+	writePDF(t, filepath.Join(sig, "signature-1.pdf"))
+	writePDF(t, filepath.Join(sig, "signature-2.pdf"))
+	signatureFiles := map[string][]string{
+		"1": {filepath.Join(sig, "signature-1.pdf")},
+		"2": {filepath.Join(sig, "signature-2.pdf")},
+	}
+	doc := func(name string) Source {
+		return Source{Path: filepath.Join(in, name)}
+	}
+	sigs := func(set string) Source {
+		return Source{Path: filepath.Join(sig, "signature-"+set+".pdf"), Signature: true, Set: set}
+	}
+
+	tests := []struct {
+		name  string
+		rules []string
+		files []string
+		want  []Source
+		err   string
+	}{
+		{
+			name:  "sets after a skipped document are left out",
+			files: []string{"T-1.pdf", "T-2.pdf"},
+			want:  []Source{doc("T-2.pdf"), sigs("2")},
+		},
+		{
+			name:  "sets before a skipped document are left out",
+			rules: []string{"*=before"},
+			files: []string{"T-1.pdf", "T-2.pdf"},
+			want:  []Source{sigs("2"), doc("T-2.pdf")},
+		},
+		{
+			name:  "sets at the end are kept",
+			rules: []string{"*=end"},
+			files: []string{"T-1.pdf", "T-2.pdf"},
+			want:  []Source{doc("T-2.pdf"), sigs("1"), sigs("2")},
+		},
+		{
+			name:  "signature sets alone fail",
+			rules: []string{"*=end"},
+			files: []string{"T-1.pdf"},
+			err:   "no documents left to merge",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestMerger(t, Options{InputDir: in, SignatureDir: sig, SignatureRules: test.rules, OnInvalid: "skip"})
+			m.invalid = newInvalidInputs()
+			files := []string{}
+			for _, file := range test.files {
+				files = append(files, filepath.Join(in, file))
+			}
+			p := ProjectPlan{Name: "T", Sources: m.addSigFiles("T", files, signatureFiles)}
+
+			result := Result{}
+			sources, _, err := m.prepareSources(p, &result)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sources, test.want) {
+				t.Errorf("got sources %+v, want %+v", sources, test.want)
+			}
+			if len(result.SkippedSources) != 1 || result.SkippedSources[0].Path != filepath.Join(in, "T-1.pdf") {
+				t.Errorf("got skipped %+v, want T-1.pdf", result.SkippedSources)
+			}
+		})
+	}
+}
+
+func TestSignatureAnchors(t *testing.T) {
+	sources := []Source{
+		{Path: "sig/signature-1.pdf", Signature: true, Set: "1"},
+		{Path: "in/T-1.pdf"},
+		{Path: "sig/signature-2.pdf", Signature: true, Set: "2"},
+		{Path: "sig/signature-2.extra.pdf", Signature: true, Set: "2"},
+		{Path: "in/T-2.pdf"},
+		{Path: "sig/signature-3.pdf", Signature: true, Set: "3"},
+	}
+	m := newTestMerger(t, Options{SignatureRules: []string{"1=before", "3=end"}})
+	got := m.signatureAnchors(ProjectPlan{Name: "T", Sources: sources})
+	want := map[int]string{0: "in/T-1.pdf", 2: "in/T-1.pdf", 3: "in/T-1.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// PropertyData is what the document property templates are executed with.
+type PropertyData struct {
+	// Project is the name of the project.
+	Project string
+	// Date is the day of the run as YYYY-MM-DD.
+	Date string
+	// SourceCount is the number of merged files, signature files included.
+	SourceCount int
+}
+
+// parseProperties parses the templates of the document properties that are
+// set, keyed by their name in the document information dictionary
+func parseProperties(opts Options) (map[string]*template.Template, error) {
+	props := make(map[string]*template.Template)
+	for name, text := range map[string]string{
+		"Title":    opts.Title,
+		"Author":   opts.Author,
+		"Subject":  opts.Subject,
+		"Creator":  opts.Creator,
+		"Keywords": opts.Keywords,
+	} {
+		if text == "" {
+			continue
+		}
+		tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", strings.ToLower(name), err.Error())
+		}
+		props[name] = tmpl
+	}
+	return props, nil
+}
+
+// addProperties replaces the document properties merged took over from its
+// first source and lists the sources in custom properties
+func (m *Merger) addProperties(p ProjectPlan, merged []byte) ([]byte, error) {
+	data := PropertyData{Project: p.Name, Date: m.runDate.Format("2006-01-02"), SourceCount: len(p.Sources)}
+
+	props := make(map[string]string)
+	for name, tmpl := range m.properties {
+		value := &strings.Builder{}
+		if err := tmpl.Execute(value, data); err != nil {
+			return nil, fmt.Errorf("unable to execute %s: %s", strings.ToLower(name), err.Error())
+		}
+		props[name] = value.String()
+	}
+
+	if m.opts.SourceProperties {
+		props["Project"] = p.Name
+		props["SourceCount"] = fmt.Sprint(len(p.Sources))
+		for i, source := range p.Sources {
+			props[fmt.Sprintf("Source%d", i+1)] = filepath.Base(source.Path)
+		}
+	}
+
+	// api.AddProperties always validates, whatever Options.OutputValidation
+	// says, the output is validated once it is complete
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	ctx, err := api.ReadContext(bytes.NewReader(merged), conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := pdfcpu.PropertiesAdd(ctx, props); err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	if err := api.WriteContext(ctx, out); err != nil {
+		return nil, err
+	}
+	m.logger.Info().Msgf("set %d document properties of %s", len(props), p.Output)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package merger
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Status is the outcome of a single project.
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+	// StatusUnchanged is a project skipped because its output is up to
+	// date.
+	StatusUnchanged Status = "unchanged"
+)
+
+// Result is the outcome of merging a single project. Err is set for failed
+// projects and Reason for skipped and unchanged ones, Sections only for
+// successful ones.
+type Result struct {
+	Project  string
+	Output   string
+	Status   Status
+	Err      error
+	Reason   string
+	Sections []Section
+	// Scaled lists the pages brought to Options.PageSize.
+	Scaled []ScaledPages
+	// SkippedSources lists the sources left out of the project.
+	SkippedSources []SkippedFile
+	// Validations lists the outcome of validating every source and the
+	// output.
+	Validations []Validation
+
+	// planHash is what the output was built from
+	planHash string
+	// bates are the Bates numbers stamped on the output
+	bates *batesNumbers
+}
+
+func skippedResult(p ProjectPlan, reason string) Result {
+	return Result{Project: p.Name, Output: p.Output, Status: StatusSkipped, Reason: reason}
+}
+
+// Count returns how many results have the given status.
+func Count(results []Result, status Status) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteSummary writes a table with one line per result, every validated file
+// with its mode and outcome, the sources that were left out, the pages that
+// were scaled and the totals.
+func WriteSummary(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tSTATUS\tOUTPUT\tVALIDATION\tDETAILS")
+	for _, r := range results {
+		details := r.Reason
+		if r.Err != nil {
+			details = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Project, r.Status, r.Output, validationSummary(r), details)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		for _, v := range r.Validations {
+			switch {
+			case v.Mode == "none":
+				fmt.Fprintf(w, "%s: %s is not validated (%s)\n", r.Project, v.Path, v.Mode)
+			case v.Err != "":
+				fmt.Fprintf(w, "%s: %s is invalid (%s): %s\n", r.Project, v.Path, v.Mode, v.Err)
+			default:
+				fmt.Fprintf(w, "%s: %s is valid (%s)\n", r.Project, v.Path, v.Mode)
+			}
+		}
+	}
+	for _, r := range results {
+		for _, s := range r.SkippedSources {
+			fmt.Fprintf(w, "%s: left out %s: %s\n", r.Project, s.Path, s.Reason)
+		}
+	}
+	for _, r := range results {
+		for _, s := range r.Scaled {
+			fmt.Fprintf(w, "%s: scaled pages %d-%d of %s from %.0fx%.0f by %.4g to %s %s\n",
+				r.Project, s.FirstPage, s.LastPage, s.Path, s.Width, s.Height, s.Scale, s.Policy, s.Size)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d projects: %d succeeded, %d unchanged, %d failed, %d skipped\n",
+		len(results), Count(results, StatusSuccess), Count(results, StatusUnchanged), Count(results, StatusFailed), Count(results, StatusSkipped))
+	return err
+}
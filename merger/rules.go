@@ -0,0 +1,80 @@
+package merger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// keyValue is a KEY=VALUE line of a file
+type keyValue struct {
+	line  int
+	key   string
+	value string
+}
+
+// cutKeyValue splits KEY=VALUE around the first =, trimming the key
+func cutKeyValue(s string) (key, value string, ok bool) {
+	key, value, found := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	return key, value, found && key != ""
+}
+
+// readKeyValueFile reads the KEY=VALUE lines of file, ignoring empty lines
+// and lines starting with #. format is the expected line for errors.
+func readKeyValueFile(file, format string) ([]keyValue, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	kvs := []keyValue{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := cutKeyValue(text)
+		if !ok {
+			// never echo the line, it may hold a password
+			return nil, fmt.Errorf("invalid line %d in %s, must be %s", line, file, format)
+		}
+		kvs = append(kvs, keyValue{line: line, key: key, value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+// checkGlob fails for a malformed glob, which would never match
+func checkGlob(glob string) error {
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid glob %q: %s", glob, err.Error())
+	}
+	return nil
+}
+
+// parseGlobRule splits a GLOB=VALUE rule and checks the glob. format is the
+// expected rule for errors.
+func parseGlobRule(rule, format string) (glob, value string, err error) {
+	glob, value, ok := cutKeyValue(rule)
+	if !ok {
+		return "", "", fmt.Errorf("must be %s", format)
+	}
+	if err := checkGlob(glob); err != nil {
+		return "", "", err
+	}
+	return glob, value, nil
+}
+
+// matchGlob reports whether the file name of source matches glob
+func matchGlob(glob string, source Source) bool {
+	ok, _ := path.Match(glob, filepath.Base(source.Path))
+	return ok
+}
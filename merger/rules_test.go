@@ -0,0 +1,71 @@
+package merger
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGlobRule(t *testing.T) {
+	tests := []struct {
+		rule  string
+		glob  string
+		value string
+		err   string
+	}{
+		{rule: "*.pdf=A4", glob: "*.pdf", value: "A4"},
+		{rule: " T_0?-*.pdf =landscape", glob: "T_0?-*.pdf", value: "landscape"},
+		// only the first = separates the glob
+		{rule: "*=a=b", glob: "*", value: "a=b"},
+		{rule: "*.pdf", err: "must be GLOB=VALUE"},
+		{rule: "=A4", err: "must be GLOB=VALUE"},
+		{rule: "[=A4", err: `invalid glob "["`},
+	}
+	for _, test := range tests {
+		glob, value, err := parseGlobRule(test.rule, "GLOB=VALUE")
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseGlobRule(%q): got error %v, want %q", test.rule, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseGlobRule(%q): %s", test.rule, err)
+			continue
+		}
+		if glob != test.glob || value != test.value {
+			t.Errorf("parseGlobRule(%q) = %q, %q, want %q, %q", test.rule, glob, value, test.glob, test.value)
+		}
+	}
+}
+
+func TestReadKeyValueFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "passwords")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("# comment\n\nuser=s3cret \n owner = =x\n")
+	kvs, err := readKeyValueFile(file, "KEY=VALUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []keyValue{{line: 3, key: "user", value: "s3cret"}, {line: 4, key: "owner", value: " =x"}}
+	if !reflect.DeepEqual(kvs, want) {
+		t.Errorf("got %+v, want %+v", kvs, want)
+	}
+
+	write("user=ok\ns3cret\n")
+	_, err = readKeyValueFile(file, "KEY=VALUE")
+	if err == nil || !strings.Contains(err.Error(), "invalid line 2") {
+		t.Fatalf("got error %v, want invalid line 2", err)
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("error %q echoes the line", err)
+	}
+}
@@ -0,0 +1,207 @@
+package merger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// scan collects the state of a single walk over the input directory
+type scan struct {
+	projects map[string][]string
+	skipped  []SkippedFile
+	// hashes holds the SHA-256 of every project file
+	hashes map[string]string
+}
+
+func (s *scan) skipFile(path, reason string) {
+	s.skipped = append(s.skipped, SkippedFile{Path: path, Reason: reason})
+}
+
+func sortProjects(projects map[string][]string) []string {
+	projectNames := []string{}
+	for p := range projects {
+		projectNames = append(projectNames, p)
+	}
+	sort.Strings(projectNames)
+	return projectNames
+}
+
+// walkDir reads a directory of the input tree and hands every entry to
+// walkFunc. ancestors holds the resolved paths of the directories currently
+// being walked so that symlinks pointing back up the tree are detected.
+func (m *Merger) walkDir(ctx context.Context, s *scan, dir string, ancestors map[string]bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if ancestors[realDir] {
+		if m.opts.Symlinks == "error" {
+			return fmt.Errorf("symlink loop detected at %s", dir)
+		}
+		m.logger.Warn().Msgf("skipping directory, symlink loop detected: %s", dir)
+		s.skipFile(dir, "symlink loop")
+		return nil
+	}
+	ancestors[realDir] = true
+	defer delete(ancestors, realDir)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := m.walkFunc(ctx, s, filepath.Join(dir, entry.Name()), info, ancestors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Merger) walkFunc(ctx context.Context, s *scan, path string, info os.FileInfo, ancestors map[string]bool) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch m.opts.Symlinks {
+		case "skip":
+			m.logger.Info().Msgf("skipping symlink: %s", path)
+			s.skipFile(path, "symlink")
+			return nil
+		case "error":
+			return fmt.Errorf("symlinks are not allowed in the input directory: %s", path)
+		}
+
+		target, err := os.Stat(path)
+		if err != nil {
+			m.logger.Warn().Msgf("skipping broken symlink %s: %s", path, err.Error())
+			s.skipFile(path, "broken symlink: "+err.Error())
+			return nil
+		}
+		info = target
+	}
+
+	if info.IsDir() {
+		if m.opts.Recursive == "" {
+			m.logger.Info().Msgf("skipping directory: %s", info.Name())
+			s.skipFile(path, "directory")
+			return nil
+		}
+		if m.isSameDir(path, m.opts.OutputDir) {
+			m.logger.Info().Msgf("skipping output directory: %s", path)
+			s.skipFile(path, "output directory")
+			return nil
+		}
+		if m.isSameDir(path, m.opts.SignatureDir) {
+			m.logger.Info().Msgf("skipping signature directory: %s", path)
+			s.skipFile(path, "signature directory")
+			return nil
+		}
+		return m.walkDir(ctx, s, path, ancestors)
+	}
+
+	_, file := filepath.Split(path)
+
+	if filepath.Ext(file) != ".pdf" {
+		m.logger.Info().Msgf("skipping non-pdf file: %s\n", path)
+		s.skipFile(path, "not a pdf file")
+		return nil
+	}
+
+	if isSignatureFile(file) {
+		m.logger.Info().Msgf("skipping signature file: %s", path)
+		s.skipFile(path, "signature file")
+		return nil
+	}
+
+	// if we're here, have some file.pdf to work with
+	projectName, ok := m.projectNameForPath(path)
+	if !ok {
+		m.logger.Warn().Msgf("skipping file not matching group pattern %s: %s", m.groupRegexp, path)
+		s.skipFile(path, "does not match group pattern")
+		return nil
+	}
+
+	s.projects[projectName] = append(s.projects[projectName], path)
+
+	hash, err := hashFile(path)
+	if err != nil {
+		m.logger.Warn().Msgf("unable to hash %s, it is not checked for duplicates: %s", path, err.Error())
+		return nil
+	}
+	s.hashes[path] = hash
+
+	return nil
+}
+
+// in directory mode files below the input root belong to the project named
+// after their relative directory, everything else is grouped by file name
+func (m *Merger) projectNameForPath(path string) (string, bool) {
+	if m.opts.Recursive == "directory" {
+		rel, err := filepath.Rel(m.opts.InputDir, filepath.Dir(path))
+		if err == nil && rel != "." {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return m.parseProjectName(filepath.Base(path))
+}
+
+func (m *Merger) isSameDir(path, dir string) bool {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return realPath == realDir
+}
+
+// match the pdf name without extension against the group pattern and return
+// the named groups, or false if it doesn't match
+func (m *Merger) parseGroups(file string) (map[string]string, bool) {
+
+	cleanPath := strings.TrimSuffix(file, filepath.Ext(file))
+
+	match := m.groupRegexp.FindStringSubmatch(cleanPath)
+	if match == nil {
+		return nil, false
+	}
+
+	groups := make(map[string]string)
+	for i, name := range m.groupRegexp.SubexpNames() {
+		if name != "" {
+			groups[name] = match[i]
+		}
+	}
+	return groups, true
+}
+
+// take the "project" group of the pdf name as the project name
+func (m *Merger) parseProjectName(file string) (string, bool) {
+	groups, ok := m.parseGroups(file)
+	if !ok || groups["project"] == "" {
+		return "", false
+	}
+	return groups["project"], true
+}
+
+// take the "seq" group of the pdf name as the document suffix, empty if the
+// pattern has no such group
+func (m *Merger) parseSequence(file string) string {
+	groups, ok := m.parseGroups(file)
+	if !ok {
+		return ""
+	}
+	return groups["seq"]
+}
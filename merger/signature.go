@@ -0,0 +1,171 @@
+package merger
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// signature sets are placed after every document with a matching suffix
+// unless a rule says otherwise
+const defaultPlacement = "after"
+
+// signatureRule decides where the signature sets matching set go in the
+// projects matching project, parsed from SET=PLACEMENT[@PROJECT]
+type signatureRule struct {
+	set       string
+	placement string
+	project   *regexp.Regexp
+}
+
+func parseSignatureRule(rule string) (signatureRule, error) {
+	set, rest, found := strings.Cut(rule, "=")
+	if !found || set == "" {
+		return signatureRule{}, fmt.Errorf("invalid signature rule %q, must be SET=PLACEMENT[@PROJECT]", rule)
+	}
+	if _, err := path.Match(set, ""); err != nil {
+		return signatureRule{}, fmt.Errorf("invalid signature set %q in rule %q: %s", set, rule, err.Error())
+	}
+
+	placement, project, hasProject := strings.Cut(rest, "@")
+	switch placement {
+	case "after", "before", "end", "none":
+	default:
+		return signatureRule{}, fmt.Errorf("unknown signature placement %q in rule %q, must be \"after\", \"before\", \"end\" or \"none\"", placement, rule)
+	}
+
+	r := signatureRule{set: set, placement: placement}
+	if hasProject {
+		reg, err := regexp.Compile(project)
+		if err != nil {
+			return signatureRule{}, fmt.Errorf("invalid project pattern in signature rule %q: %s", rule, err.Error())
+		}
+		r.project = reg
+	}
+	return r, nil
+}
+
+func (r signatureRule) matches(set, project string) bool {
+	if ok, _ := path.Match(r.set, set); !ok {
+		return false
+	}
+	return r.project == nil || r.project.MatchString(project)
+}
+
+// the placement of the first matching rule, or the default
+func (m *Merger) signaturePlacement(set, project string) string {
+	for _, r := range m.signatureRules {
+		if r.matches(set, project) {
+			return r.placement
+		}
+	}
+	return defaultPlacement
+}
+
+func isSignatureFile(name string) bool {
+	return strings.Contains(name, "signature")
+}
+
+// parseSignatureFiles walks the signature directory for files named
+// signature-SUFFIX.pdf and groups them by SUFFIX
+func (m *Merger) parseSignatureFiles() (map[string][]string, error) {
+	signatureFiles := make(map[string][]string)
+	if _, err := os.Stat(m.opts.SignatureDir); os.IsNotExist(err) {
+		return signatureFiles, nil
+	}
+	err := filepath.WalkDir(m.opts.SignatureDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isSignatureFile(d.Name()) {
+			basename := strings.Replace(strings.Replace(d.Name(), filepath.Ext(d.Name()), "", -1), "signature-", "", -1)
+			m.logger.Debug().Msgf("signature file basename: %v\n", basename)
+			suffix := strings.Split(basename, ".")[0]
+			signatureFiles[suffix] = append(signatureFiles[suffix], path)
+			m.logger.Debug().Msgf("adding file %v to suffix %v\n", path, suffix)
+		}
+		return nil
+	})
+	return signatureFiles, err
+}
+
+// addSigFiles places the signature sets among the ordered project files
+// following the signature rules: before or after every project file with
+// the same suffix, or once at the end of the project
+func (m *Merger) addSigFiles(project string, projectFiles []string, signatureFiles map[string][]string) []Source {
+	sets := []string{}
+	for set := range signatureFiles {
+		sets = append(sets, set)
+	}
+	sort.Strings(sets)
+
+	placements := make(map[string]string)
+	for _, set := range sets {
+		placements[set] = m.signaturePlacement(set, project)
+		m.logger.Debug().Msgf("signature set %v placed %v in project %v\n", set, placements[set], project)
+	}
+
+	signatures := func(set string) []Source {
+		sources := []Source{}
+		for _, file := range signatureFiles[set] {
+			sources = append(sources, Source{Path: file, Signature: true, Set: set})
+		}
+		return sources
+	}
+
+	sources := []Source{}
+	for _, name := range projectFiles {
+		suffix := m.parseSequence(filepath.Base(name))
+		m.logger.Debug().Msgf("found suffix of file: %v, suffix %v\n", name, suffix)
+
+		if suffix != "" && placements[suffix] == "before" {
+			sources = append(sources, signatures(suffix)...)
+		}
+		sources = append(sources, Source{Path: name})
+		if suffix != "" && placements[suffix] == "after" {
+			sources = append(sources, signatures(suffix)...)
+		}
+	}
+	for _, set := range sets {
+		if placements[set] == "end" {
+			sources = append(sources, signatures(set)...)
+		}
+	}
+
+	m.logger.Debug().Msgf("project files after adding sig files: %#v\n", sources)
+	return sources
+}
+
+// signatureAnchors returns the document each signature source of p placed
+// before or after a document belongs to, by index. Sets placed at the end
+// belong to no document.
+func (m *Merger) signatureAnchors(p ProjectPlan) map[int]string {
+	anchors := make(map[int]string)
+	for i, source := range p.Sources {
+		if !source.Signature {
+			continue
+		}
+		step := 0
+		switch m.signaturePlacement(source.Set, p.Name) {
+		case "after":
+			step = -1
+		case "before":
+			step = 1
+		default:
+			continue
+		}
+		j := i + step
+		for j >= 0 && j < len(p.Sources) && p.Sources[j].Signature {
+			j += step
+		}
+		if j >= 0 && j < len(p.Sources) {
+			anchors[i] = p.Sources[j].Path
+		}
+	}
+	return anchors
+}
@@ -0,0 +1,116 @@
+package merger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSignatureRule(t *testing.T) {
+	tests := []struct {
+		rule      string
+		set       string
+		placement string
+		project   string
+		err       string
+	}{
+		{rule: "2=before", set: "2", placement: "before"},
+		{rule: "*=end", set: "*", placement: "end"},
+		{rule: "0?=none@^T_0[12]$", set: "0?", placement: "none", project: "^T_0[12]$"},
+		// the project pattern may contain = and @
+		{rule: "1=after@a=b@c", set: "1", placement: "after", project: "a=b@c"},
+		{rule: "2", err: "must be SET=PLACEMENT[@PROJECT]"},
+		{rule: "=after", err: "must be SET=PLACEMENT[@PROJECT]"},
+		{rule: "[=after", err: "invalid signature set"},
+		{rule: "2=middle", err: "unknown signature placement"},
+		{rule: "2=after@(", err: "invalid project pattern"},
+	}
+	for _, test := range tests {
+		r, err := parseSignatureRule(test.rule)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseSignatureRule(%q): got error %v, want %q", test.rule, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSignatureRule(%q): %s", test.rule, err)
+			continue
+		}
+		project := ""
+		if r.project != nil {
+			project = r.project.String()
+		}
+		if r.set != test.set || r.placement != test.placement || project != test.project {
+			t.Errorf("parseSignatureRule(%q) = %q, %q, %q, want %q, %q, %q",
+				test.rule, r.set, r.placement, project, test.set, test.placement, test.project)
+		}
+	}
+}
+
+func TestAddSigFiles(t *testing.T) {
+	signatureFiles := map[string][]string{
+		"1": {"sig/signature-1.pdf"},
+		"2": {"sig/signature-2.pdf", "sig/signature-2.extra.pdf"},
+	}
+	files := []string{"in/T-1.pdf", "in/T-2.pdf", "in/T-3.pdf"}
+	sig := func(set, file string) Source {
+		return Source{Path: "sig/" + file, Signature: true, Set: set}
+	}
+	doc := func(name string) Source {
+		return Source{Path: "in/" + name}
+	}
+
+	tests := []struct {
+		name  string
+		rules []string
+		want  []Source
+	}{
+		{
+			name: "after every document by default",
+			want: []Source{
+				doc("T-1.pdf"), sig("1", "signature-1.pdf"),
+				doc("T-2.pdf"), sig("2", "signature-2.pdf"), sig("2", "signature-2.extra.pdf"),
+				doc("T-3.pdf"),
+			},
+		},
+		{
+			name:  "before, end and none",
+			rules: []string{"1=before", "2=end", "*=none"},
+			want: []Source{
+				sig("1", "signature-1.pdf"), doc("T-1.pdf"),
+				doc("T-2.pdf"),
+				doc("T-3.pdf"),
+				sig("2", "signature-2.pdf"), sig("2", "signature-2.extra.pdf"),
+			},
+		},
+		{
+			name:  "the first matching rule wins",
+			rules: []string{"1=none", "*=before"},
+			want: []Source{
+				doc("T-1.pdf"),
+				sig("2", "signature-2.pdf"), sig("2", "signature-2.extra.pdf"), doc("T-2.pdf"),
+				doc("T-3.pdf"),
+			},
+		},
+		{
+			name:  "rules limited to other projects don't apply",
+			rules: []string{"*=none@^U$", "1=end@^T$"},
+			want: []Source{
+				doc("T-1.pdf"),
+				doc("T-2.pdf"), sig("2", "signature-2.pdf"), sig("2", "signature-2.extra.pdf"),
+				doc("T-3.pdf"),
+				sig("1", "signature-1.pdf"),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestMerger(t, Options{SignatureRules: test.rules})
+			got := m.addSigFiles("T", files, signatureFiles)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
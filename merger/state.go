@@ -0,0 +1,204 @@
+package merger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stateFile in the output directory remembers what every output was built
+// from, so unchanged projects can be skipped
+const stateFile = ".pdfmerger-state.json"
+
+// stateKeyFile in the user's configuration directory holds the key passwords
+// are hashed with for the build state. It is kept out of the output
+// directory so the state file alone gives nothing to guess passwords from.
+const stateKeyFile = "pdfmerger/state-key"
+
+// builtOutput is the plan an output was built from and the output as it was
+// written
+type builtOutput struct {
+	PlanHash string `json:"plan_hash"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"`
+}
+
+// buildState maps every output to what it was built from
+type buildState map[string]builtOutput
+
+func loadBuildState(dir string) (buildState, error) {
+	state := make(buildState)
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read build state: %s", err.Error())
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid build state %s: %s", filepath.Join(dir, stateFile), err.Error())
+	}
+	return state, nil
+}
+
+func (s buildState) save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := writeTemp(filepath.Join(dir, stateFile), data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, stateFile)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// upToDate tells whether output was built from a plan with hash and hasn't
+// changed since
+func (s buildState) upToDate(output, hash string) bool {
+	built, ok := s[output]
+	if !ok || built.PlanHash != hash {
+		return false
+	}
+	info, err := os.Stat(output)
+	return err == nil && info.Size() == built.Size && info.ModTime().UnixNano() == built.ModTime
+}
+
+// planHash hashes everything the output of p depends on: the options, the
+// sources in order and their sizes and modification times, and the
+// passwords
+func (m *Merger) planHash(p ProjectPlan) (string, error) {
+	opts := m.opts
+	// options that don't change the output, and the passwords and the files
+	// holding them, which are only part of the password digest
+	opts.InputDir, opts.Manifest = "", ""
+	opts.Jobs, opts.FailFast, opts.Force = 0, false, false
+	opts.UserPassword, opts.OwnerPassword, opts.InputPassword = "", "", ""
+	opts.PasswordFile, opts.PasswordCSV, opts.InputPasswordFile = "", "", ""
+	p.Skipped = nil
+
+	type sourceFile struct {
+		Path    string `json:"path"`
+		Size    int64  `json:"size"`
+		ModTime int64  `json:"mod_time"`
+	}
+	files := []sourceFile{}
+	for _, source := range p.Sources {
+		info, err := os.Stat(source.Path)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, sourceFile{Path: source.Path, Size: info.Size(), ModTime: info.ModTime().UnixNano()})
+	}
+
+	passwords, err := m.passwordDigest(p)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(struct {
+		Options   Options
+		Plan      ProjectPlan
+		Files     []sourceFile
+		Passwords string
+	}{opts, p, files, passwords}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// usesPasswords tells whether outputs are encrypted or inputs decrypted with
+// any passwords
+func (m *Merger) usesPasswords() bool {
+	return m.opts.Encrypt || m.opts.InputPassword != "" || len(m.inputPasswordRules) > 0
+}
+
+// passwordDigest returns an HMAC of the passwords the output of p is
+// encrypted with and its sources are decrypted with, as read from the
+// environment and the password files, empty without any passwords
+func (m *Merger) passwordDigest(p ProjectPlan) (string, error) {
+	if !m.usesPasswords() {
+		return "", nil
+	}
+	if len(m.stateKey) == 0 {
+		return "", errors.New("no key to hash the passwords with")
+	}
+
+	secrets := [][]string{}
+	if m.opts.Encrypt {
+		// a project without passwords fails before its hash is saved
+		if pw, err := m.passwordsFor(p.Name); err == nil {
+			secrets = append(secrets, []string{pw.User, pw.owner()})
+		}
+	}
+	for _, source := range p.Sources {
+		secrets = append(secrets, m.inputPasswords(source))
+	}
+
+	mac := hmac.New(sha256.New, m.stateKey)
+	if err := json.NewEncoder(mac).Encode(secrets); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// loadStateKey reads the key passwords are hashed with, creating a random
+// one the first time
+func loadStateKey() ([]byte, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, stateKeyFile)
+	key, err := os.ReadFile(file)
+	if err == nil {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("empty key in %s", file)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	// another run may create it at the same time, the first one wins
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+// builtFrom returns what the freshly written output of p was built from
+func builtFrom(p ProjectPlan, hash string) (builtOutput, error) {
+	info, err := os.Stat(p.Output)
+	if err != nil {
+		return builtOutput{}, err
+	}
+	return builtOutput{PlanHash: hash, Size: info.Size(), ModTime: info.ModTime().UnixNano()}, nil
+}
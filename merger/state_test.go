@@ -0,0 +1,152 @@
+package merger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanHash(t *testing.T) {
+	in := t.TempDir()
+	writeFiles(t, in, "T-1.pdf", "T-2.pdf")
+	p := ProjectPlan{
+		Name:    "T",
+		Output:  "/out/T.pdf",
+		Order:   "natural",
+		Sources: []Source{{Path: filepath.Join(in, "T-1.pdf")}, {Path: filepath.Join(in, "T-2.pdf")}},
+	}
+	pwFile := func(name, contents string) string {
+		file := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(file, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	base := Options{InputDir: in, OutputDir: "/out", InputPassword: "in", Encrypt: true, UserPassword: "u", OwnerPassword: "o"}
+	hash := func(t *testing.T, opts Options, p ProjectPlan) string {
+		t.Helper()
+		m := newTestMerger(t, opts)
+		m.stateKey = []byte("key")
+		h, err := m.planHash(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	want := hash(t, base, p)
+
+	same := []struct {
+		name   string
+		change func(*Options, *ProjectPlan)
+	}{
+		{"nothing", func(*Options, *ProjectPlan) {}},
+		{"jobs, fail-fast and force", func(o *Options, _ *ProjectPlan) { o.Jobs, o.FailFast, o.Force = 4, true, true }},
+		{"files left out when planning", func(_ *Options, p *ProjectPlan) {
+			p.Skipped = []SkippedFile{{Path: "x.txt", Reason: "not a pdf file"}}
+		}},
+		{"the same passwords from a password file", func(o *Options, _ *ProjectPlan) {
+			o.UserPassword, o.OwnerPassword = "", ""
+			o.PasswordFile = pwFile("passwords", "user=u\nowner=o\n")
+		}},
+	}
+	for _, test := range same {
+		t.Run("same with "+test.name, func(t *testing.T) {
+			opts, p := base, p
+			test.change(&opts, &p)
+			if got := hash(t, opts, p); got != want {
+				t.Errorf("hash changed")
+			}
+		})
+	}
+
+	changed := []struct {
+		name   string
+		change func(*Options, *ProjectPlan)
+	}{
+		{"an option", func(o *Options, _ *ProjectPlan) { o.Bookmarks = true }},
+		{"the order of sources", func(_ *Options, p *ProjectPlan) {
+			p.Sources = []Source{p.Sources[1], p.Sources[0]}
+		}},
+		{"the user password", func(o *Options, _ *ProjectPlan) { o.UserPassword = "u2" }},
+		{"the owner password", func(o *Options, _ *ProjectPlan) { o.OwnerPassword = "o2" }},
+		{"the input password", func(o *Options, _ *ProjectPlan) { o.InputPassword = "in2" }},
+		{"the contents of the password file", func(o *Options, _ *ProjectPlan) {
+			o.UserPassword, o.OwnerPassword = "", ""
+			o.PasswordFile = pwFile("passwords", "user=u\nowner=o2\n")
+		}},
+		{"an input password rule", func(o *Options, _ *ProjectPlan) {
+			o.InputPasswordFile = pwFile("input-passwords", "T-2.pdf=secret\n")
+		}},
+	}
+	for _, test := range changed {
+		t.Run("changed with "+test.name, func(t *testing.T) {
+			opts, p := base, p
+			test.change(&opts, &p)
+			if got := hash(t, opts, p); got == want {
+				t.Errorf("hash didn't change")
+			}
+		})
+	}
+
+	t.Run("changed with the key", func(t *testing.T) {
+		m := newTestMerger(t, base)
+		m.stateKey = []byte("other key")
+		if got, err := m.planHash(p); err != nil || got == want {
+			t.Errorf("got %q, %v, want a different hash", got, err)
+		}
+	})
+
+	t.Run("error without a key", func(t *testing.T) {
+		m := newTestMerger(t, base)
+		if _, err := m.planHash(p); err == nil {
+			t.Errorf("got no error")
+		}
+	})
+
+	t.Run("changed with a source", func(t *testing.T) {
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(p.Sources[0].Path, later, later); err != nil {
+			t.Fatal(err)
+		}
+		if got := hash(t, base, p); got == want {
+			t.Errorf("hash didn't change")
+		}
+	})
+}
+
+func TestLoadStateKey(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+
+	key, err := loadStateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 32 {
+		t.Errorf("got a key of %d bytes, want 32", len(key))
+	}
+	again, err := loadStateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Errorf("got a new key on the second load")
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(configDir, stateKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("got permissions %o, want 600", perm)
+	}
+}
@@ -0,0 +1,199 @@
+package merger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// layout of the table of contents on an A4 page, in points from the lower
+// left corner
+const (
+	tocFont         = "Helvetica"
+	tocHeadingFont  = "Helvetica-Bold"
+	tocFontSize     = 11
+	tocHeadingSize  = 16
+	tocLeft         = 60
+	tocRight        = 535
+	tocHeadingY     = 770
+	tocFirstLineY   = 730
+	tocLineHeight   = 18
+	tocLinesPerPage = 38
+)
+
+// tocEntry is a single line of the table of contents
+type tocEntry struct {
+	title     string
+	firstPage int
+	lastPage  int
+	// where the line ended up, page of the table of contents and baseline
+	page int
+	y    float64
+}
+
+// the subset of the pdfcpu create JSON the table of contents is built with
+type tocFontJSON struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+type tocTextJSON struct {
+	Value string      `json:"value"`
+	Pos   [2]float64  `json:"pos"`
+	Align string      `json:"align,omitempty"`
+	Font  tocFontJSON `json:"font"`
+}
+
+type tocPageJSON struct {
+	Content struct {
+		Text []tocTextJSON `json:"text"`
+	} `json:"content"`
+}
+
+type tocJSON struct {
+	Paper  string                  `json:"paper"`
+	Origin string                  `json:"origin"`
+	Pages  map[string]*tocPageJSON `json:"pages"`
+}
+
+// tocPageCount returns how many pages a table of contents listing sections
+// takes
+func tocPageCount(sections []Section) int {
+	return (len(documents(sections)) + tocLinesPerPage - 1) / tocLinesPerPage
+}
+
+// tableOfContents shifts sections behind the pages of a table of contents
+// listing them and returns those pages together with the entries to link
+func (m *Merger) tableOfContents(p ProjectPlan, sections []Section) ([]byte, []tocEntry, error) {
+	pages := tocPageCount(sections)
+	blank := m.opts.Duplex && pages%2 == 1
+	if blank {
+		pages++
+	}
+	for i := range sections {
+		sections[i].FirstPage += pages
+		sections[i].LastPage += pages
+	}
+
+	entries := []tocEntry{}
+	toc := tocJSON{Paper: "A4", Origin: "LowerLeft", Pages: make(map[string]*tocPageJSON)}
+	for i, section := range documents(sections) {
+		title, _, err := m.title(p, section)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry := tocEntry{
+			title:     title,
+			firstPage: section.FirstPage,
+			lastPage:  section.LastPage,
+			page:      i/tocLinesPerPage + 1,
+			y:         float64(tocFirstLineY - (i%tocLinesPerPage)*tocLineHeight),
+		}
+
+		page, ok := toc.Pages[fmt.Sprint(entry.page)]
+		if !ok {
+			page = &tocPageJSON{}
+			heading := "Contents of " + p.Name
+			if entry.page > 1 {
+				heading += " (continued)"
+			}
+			page.Content.Text = append(page.Content.Text, tocTextJSON{
+				Value: heading,
+				Pos:   [2]float64{tocLeft, tocHeadingY},
+				Font:  tocFontJSON{Name: tocHeadingFont, Size: tocHeadingSize},
+			})
+			toc.Pages[fmt.Sprint(entry.page)] = page
+		}
+
+		pageRange := entry.pageRange()
+		maxWidth := tocRight - tocLeft - font.TextWidth(pageRange, tocFont, tocFontSize) - 2*tocLineHeight
+		page.Content.Text = append(page.Content.Text,
+			tocTextJSON{
+				Value: truncate(title, maxWidth),
+				Pos:   [2]float64{tocLeft, entry.y},
+				Font:  tocFontJSON{Name: tocFont, Size: tocFontSize},
+			},
+			tocTextJSON{
+				Value: pageRange,
+				Pos:   [2]float64{tocRight, entry.y},
+				Align: "Right",
+				Font:  tocFontJSON{Name: tocFont, Size: tocFontSize},
+			})
+		entries = append(entries, entry)
+	}
+
+	if blank {
+		page := &tocPageJSON{}
+		if m.opts.BlankPageText != "" {
+			page.Content.Text = append(page.Content.Text, tocTextJSON{
+				Value: m.opts.BlankPageText,
+				Pos:   [2]float64{(tocLeft + tocRight) / 2, 842 / 2},
+				Align: "Center",
+				Font:  tocFontJSON{Name: tocFont, Size: 12},
+			})
+		}
+		toc.Pages[fmt.Sprint(pages)] = page
+	}
+
+	b, err := json.Marshal(toc)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := api.Create(nil, bytes.NewReader(b), buf, model.NewDefaultConfiguration()); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), entries, nil
+}
+
+func (e tocEntry) pageRange() string {
+	if e.firstPage == e.lastPage {
+		return fmt.Sprint(e.firstPage)
+	}
+	return fmt.Sprintf("%d-%d", e.firstPage, e.lastPage)
+}
+
+// truncate shortens title until it fits into width on a table of contents
+// line
+func truncate(title string, width float64) string {
+	if font.TextWidth(title, tocFont, tocFontSize) <= width {
+		return title
+	}
+	runes := []rune(title)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		s := strings.TrimSpace(string(runes)) + "..."
+		if font.TextWidth(s, tocFont, tocFontSize) <= width {
+			return s
+		}
+	}
+	return ""
+}
+
+// addTOCLinks makes every line of the table of contents a link to the first
+// page of its document
+func (m *Merger) addTOCLinks(p ProjectPlan, entries []tocEntry, merged []byte) ([]byte, error) {
+	links := make(map[int][]model.AnnotationRenderer)
+	for i, entry := range entries {
+		rect := types.NewRectangle(tocLeft-2, entry.y-2, tocRight+2, entry.y+tocFontSize+4)
+		dest := &model.Destination{Typ: model.DestFit, PageNr: entry.firstPage}
+		link := model.NewLinkAnnotation(*rect, nil, dest, "", fmt.Sprintf("toc%d", i+1), 0, nil, false)
+		links[entry.page] = append(links[entry.page], link)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationNone
+	conf.Cmd = model.ADDANNOTATIONS
+	out := &bytes.Buffer{}
+	if err := api.AddAnnotationsMap(bytes.NewReader(merged), out, links, conf); err != nil {
+		return nil, err
+	}
+	m.logger.Info().Msgf("added a table of contents with %d entries to %s", len(entries), p.Output)
+	return out.Bytes(), nil
+}
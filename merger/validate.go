@@ -0,0 +1,25 @@
+package merger
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ValidationResult is the outcome of validating a single PDF file, Err is
+// nil for valid files.
+type ValidationResult struct {
+	Path string
+	Err  error
+}
+
+// ValidateFiles validates every file with the default pdfcpu configuration.
+// Unlike api.ValidateFiles, which only prints failures to stderr, it returns
+// a result per file.
+func ValidateFiles(files []string) []ValidationResult {
+	results := []ValidationResult{}
+	for _, file := range files {
+		err := api.ValidateFile(file, model.NewDefaultConfiguration())
+		results = append(results, ValidationResult{Path: file, Err: err})
+	}
+	return results
+}
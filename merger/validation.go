@@ -0,0 +1,76 @@
+package merger
+
+import (
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// pdfcpu validation modes by name
+var validationModes = map[string]int{
+	"strict":  model.ValidationStrict,
+	"relaxed": model.ValidationRelaxed,
+	"none":    model.ValidationNone,
+}
+
+// Validation is the outcome of validating an input or the output of a
+// project.
+type Validation struct {
+	Path string
+	// Mode is "strict", "relaxed" or "none" if the file wasn't validated.
+	Mode string
+	// Err is why the file is invalid, empty if it is valid.
+	Err string
+}
+
+func checkValidationMode(name, mode string) error {
+	if _, ok := validationModes[mode]; !ok {
+		return fmt.Errorf("unknown %s validation %q, must be \"strict\", \"relaxed\" or \"none\"", name, mode)
+	}
+	return nil
+}
+
+// validationConf returns a configuration validating in mode
+func (m *Merger) validationConf(mode string) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = validationModes[mode]
+	conf.ValidateLinks = m.opts.ValidateLinks
+	return conf
+}
+
+// validationSummary sums up the validations of r, such as "inputs 4/5
+// valid, output valid"
+func validationSummary(r Result) string {
+	inputs, valid := 0, 0
+	output := ""
+	for _, v := range r.Validations {
+		outcome := "valid"
+		if v.Mode == "none" {
+			outcome = "not validated"
+		} else if v.Err != "" {
+			outcome = "invalid"
+		}
+		if v.Path == r.Output {
+			output = "output " + outcome
+			continue
+		}
+		if v.Mode != "none" {
+			inputs++
+			if v.Err == "" {
+				valid++
+			}
+		}
+	}
+
+	summary := ""
+	if inputs > 0 {
+		summary = fmt.Sprintf("inputs %d/%d valid", valid, inputs)
+	}
+	if output != "" {
+		if summary != "" {
+			summary += ", "
+		}
+		summary += output
+	}
+	return summary
+}